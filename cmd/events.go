@@ -1,26 +1,37 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"math"
 	"math/rand"
+	"os"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
 
 	"github.com/irfansharif/zellij/internal/app"
-	"github.com/irfansharif/zellij/internal/memory"
+	"github.com/irfansharif/zellij/internal/gen"
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/mesh"
 )
 
 const repeatInterval = 125 * time.Millisecond // time between successive regenerations/pans when pressed down
 const basePanDistance = 100.0
+const rotationStep = math.Pi / 12 // 15°
+const scaleStep = 1.1
+const defaultScenePath = "zellij.scene.json"
 
 // EventHandlers manages all event handling for the application.
 type EventHandlers struct {
 	application *app.App
 
+	// Scene file to save to/open from.
+	scenePath string
+
 	// Space, or shift+space triggers regenerating clusters (with the shift
 	// allowing to go back). If held down, we do so continuously.
 	spaceHeld, shiftHeld bool
@@ -37,18 +48,32 @@ type EventHandlers struct {
 	dragStartMouseX, dragStartMouseY float64
 	dragStartPanX, dragStartPanY     float64
 
+	// Alt+drag moves the cluster under the cursor instead of panning, if
+	// there's one.
+	movingCluster                      *app.Cluster
+	moveStartCanvasX, moveStartCanvasY float64
+	moveStartClusterPos                geom.Point
+
 	// Current mouse position in canvas coordinates.
 	mouseCanvasX, mouseCanvasY float64
 
 	// Input buffer for numeric input (complexity or batch operations).
 	// Accumulates digits and comma until action key (Space, C, D) is pressed.
 	inputBuffer string
+
+	// ':' opens a prompt for features (e.g. density=12,lines=30), typed into
+	// promptBuffer until Enter. They're then set on the next cluster created
+	// or regenerated (C or Space) instead of being drawn from its seed.
+	prompting    bool
+	promptBuffer string
+	overrides    *gen.Overrides
 }
 
 // NewEventHandlers creates a new event handlers manager.
-func NewEventHandlers(application *app.App) *EventHandlers {
+func NewEventHandlers(application *app.App, scenePath string) *EventHandlers {
 	eh := &EventHandlers{
 		application:   application,
+		scenePath:     scenePath,
 		lastRegenTime: time.Now(),
 		lastPanTime:   time.Now(),
 	}
@@ -61,8 +86,11 @@ func (eh *EventHandlers) SetupCallbacks(window *glfw.Window) {
 	window.SetKeyCallback(func(wnd *glfw.Window, key glfw.Key, _ int, action glfw.Action, mods glfw.ModifierKey) {
 		eh.handleKey(key, action, mods) // for various actions
 	})
+	window.SetCharCallback(func(wnd *glfw.Window, char rune) {
+		eh.handleChar(char) // for typing features
+	})
 	window.SetMouseButtonCallback(func(wnd *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
-		eh.handleMouseButton(button, action) // for panning
+		eh.handleMouseButton(button, action, mods) // for panning/moving clusters
 	})
 	window.SetCursorPosCallback(func(wnd *glfw.Window, xpos, ypos float64) {
 		eh.handleCursorPos(xpos, ypos) // for tracking where the mouse currently is (used in regen, etc.)
@@ -83,7 +111,9 @@ func (eh *EventHandlers) updateRendererView() {
 	eh.application.Renderer.SetView(cw, ch, view.Zoom, view.PanX, view.PanY)
 }
 
-// handleFramebufferSize handles window resize events.
+// handleFramebufferSize handles window resize events. Cluster geometry is
+// sized in world units, independent of the framebuffer, so only the view
+// changes.
 func (eh *EventHandlers) handleFramebufferSize(newW, newH int) {
 	eh.application.View.SetViewport(newW, newH)
 	eh.updateRendererView()
@@ -91,6 +121,13 @@ func (eh *EventHandlers) handleFramebufferSize(newW, newH int) {
 
 // handleKey handles keyboard input events.
 func (eh *EventHandlers) handleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if eh.prompting {
+		if action == glfw.Press || action == glfw.Repeat {
+			eh.handlePromptKey(key)
+		}
+		return
+	}
+
 	if action == glfw.Press {
 		// Handle number keys for input.
 		if key >= glfw.Key0 && key <= glfw.Key9 {
@@ -104,9 +141,17 @@ func (eh *EventHandlers) handleKey(key glfw.Key, action glfw.Action, mods glfw.M
 			return
 		}
 
-		// Handle Escape key to clear input buffer.
+		// Handle Escape key to clear input buffer (and features).
 		if key == glfw.KeyEscape {
 			eh.inputBuffer = ""
+			eh.overrides = nil
+			return
+		}
+
+		// Handle ':' key to open the features prompt.
+		if key == glfw.KeySemicolon && (mods&glfw.ModShift) != 0 {
+			eh.prompting, eh.promptBuffer = true, ""
+			log.Printf("Features for the next cluster (e.g. density=12,lines=30,focus=sixteen,shimmer=3), then Enter:")
 			return
 		}
 
@@ -132,6 +177,34 @@ func (eh *EventHandlers) handleKey(key glfw.Key, action glfw.Action, mods glfw.M
 		if action == glfw.Press {
 			eh.handleDeleteClusterKey()
 		}
+	case glfw.KeyS:
+		if action == glfw.Press && (mods&(glfw.ModSuper|glfw.ModControl)) != 0 {
+			eh.handleSaveSceneKey((mods & glfw.ModShift) != 0)
+		}
+	case glfw.KeyO:
+		if action == glfw.Press && (mods&(glfw.ModSuper|glfw.ModControl)) != 0 {
+			eh.handleOpenSceneKey()
+		}
+	case glfw.KeyLeftBracket, glfw.KeyRightBracket:
+		if action == glfw.Press || action == glfw.Repeat {
+			eh.handleTransformKey(key == glfw.KeyRightBracket, (mods&glfw.ModShift) != 0)
+		}
+	case glfw.KeyZ:
+		if action == glfw.Press && (mods&(glfw.ModSuper|glfw.ModControl)) != 0 {
+			eh.handleUndoKey((mods & glfw.ModShift) != 0)
+		}
+	case glfw.KeyE:
+		if action == glfw.Press {
+			eh.handleExportKey((mods & glfw.ModShift) != 0)
+		}
+	case glfw.KeyG:
+		if action == glfw.Press {
+			eh.handleLatticeKey()
+		}
+	case glfw.KeyW:
+		if action == glfw.Press {
+			eh.handleWallpaperKey()
+		}
 	case glfw.KeyTab:
 		if action == glfw.Press {
 			next := true
@@ -174,8 +247,9 @@ func (eh *EventHandlers) handleRegenerationKeys(action glfw.Action, mods glfw.Mo
 			eh.spaceHeld = true
 			eh.shiftHeld = false
 		}
-		eh.handleSeedChange(shiftHeld)
-		eh.application.RegenerateClosest(eh.mouseCanvasX, eh.mouseCanvasY, complexity)
+		if err := eh.application.RegenerateClosest(eh.mouseCanvasX, eh.mouseCanvasY, seedDelta(!shiftHeld), complexity, eh.takeOverrides()); err != nil {
+			log.Printf("Failed to regenerate cluster: %v", err)
+		}
 		w, h := eh.application.Window.GetFramebufferSize()
 		eh.application.PrepareRenderer(w, h)
 		eh.lastRegenTime = time.Now()
@@ -230,7 +304,7 @@ func (eh *EventHandlers) performPan(dx, dy float64) {
 // and also set cursor for subsequent tabs/shift+tabs).
 func (eh *EventHandlers) handleResetKey() {
 	view := eh.application.View
-	clusters := eh.application.ClusterManager.FindClosestClusters(eh.mouseCanvasX, eh.mouseCanvasY)
+	clusters := eh.application.ClusterManager.FindClosestClusters(eh.mouseCanvasX, eh.mouseCanvasY, 1)
 	if len(clusters) > 0 {
 		cluster := clusters[0]
 		view.ResetTo(cluster.CanvasPos)
@@ -242,21 +316,13 @@ func (eh *EventHandlers) handleResetKey() {
 	eh.updateMouseCanvasPos(mouseX, mouseY)
 }
 
-// handleSeedChange updates the seed of the closest cluster.
-func (eh *EventHandlers) handleSeedChange(increment bool) {
-	clusters := eh.application.ClusterManager.FindClosestClusters(
-		eh.mouseCanvasX,
-		eh.mouseCanvasY,
-	)
-	if len(clusters) == 0 {
-		return // nothing to do
-	}
-	cluster := clusters[0]
+// seedDelta returns the seed step for regenerations: forwards for space,
+// backwards for shift+space.
+func seedDelta(increment bool) int64 {
 	if increment {
-		cluster.SetSeed(cluster.Seed + 1)
-	} else {
-		cluster.SetSeed(cluster.Seed - 1)
+		return 1
 	}
+	return -1
 }
 
 // handleContinuousRegeneration handles continuous regeneration while space is held.
@@ -270,8 +336,10 @@ func (eh *EventHandlers) handleContinuousRegeneration() {
 		return // not enough time has passed since the last regeneration
 	}
 
-	eh.handleSeedChange(eh.spaceHeld /* increment*/)
-	eh.application.RegenerateClosest(eh.mouseCanvasX, eh.mouseCanvasY, nil /*complexity*/) // use existing complexity for continuous regeneration
+	// Use existing complexity and features for continuous regeneration.
+	if err := eh.application.RegenerateClosest(eh.mouseCanvasX, eh.mouseCanvasY, seedDelta(eh.spaceHeld), nil /*complexity*/, nil /*overrides*/); err != nil {
+		log.Printf("Failed to regenerate cluster: %v", err)
+	}
 	w, h := eh.application.Window.GetFramebufferSize()
 	eh.application.PrepareRenderer(w, h)
 	eh.lastRegenTime = now
@@ -292,16 +360,21 @@ func (eh *EventHandlers) handleContinuousPanning() {
 	eh.lastPanTime = now
 }
 
-// handleMouseButton handles mouse button events for panning.
-func (eh *EventHandlers) handleMouseButton(button glfw.MouseButton, action glfw.Action) {
+// handleMouseButton handles mouse button events for panning, or moving
+// clusters with alt held.
+func (eh *EventHandlers) handleMouseButton(button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
 	if button != glfw.MouseButtonLeft {
 		return // nothing to do
 	}
 
 	switch action {
 	case glfw.Press:
+		if (mods&glfw.ModAlt) != 0 && eh.startMoving() {
+			return
+		}
 		eh.startPanning()
 	case glfw.Release:
+		eh.stopMoving()
 		eh.stopPanning()
 	}
 }
@@ -329,6 +402,46 @@ func (eh *EventHandlers) updateMouseCanvasPos(mouseX, mouseY float64) {
 func (eh *EventHandlers) handleCursorPos(xpos, ypos float64) {
 	eh.updateMouseCanvasPos(xpos, ypos)
 	eh.updatePanning(xpos, ypos)
+	eh.updateMoving()
+}
+
+// startMoving starts moving the cluster under the cursor, if any. Returns
+// false if there's none.
+func (eh *EventHandlers) startMoving() bool {
+	cluster := eh.application.ClusterAt(eh.mouseCanvasX, eh.mouseCanvasY)
+	if cluster == nil {
+		return false
+	}
+
+	eh.movingCluster = cluster
+	eh.moveStartCanvasX, eh.moveStartCanvasY = eh.mouseCanvasX, eh.mouseCanvasY
+	eh.moveStartClusterPos = cluster.CanvasPos
+	eh.application.BeginChange() // undo the entire drag at once
+	return true
+}
+
+// stopMoving ends the move operation, if any.
+func (eh *EventHandlers) stopMoving() {
+	if eh.movingCluster == nil {
+		return
+	}
+	eh.movingCluster = nil
+	eh.application.EndChange()
+}
+
+// updateMoving moves the cluster being dragged to follow the mouse.
+func (eh *EventHandlers) updateMoving() {
+	if eh.movingCluster == nil {
+		return
+	}
+
+	dx := eh.mouseCanvasX - eh.moveStartCanvasX
+	dy := eh.mouseCanvasY - eh.moveStartCanvasY
+	pos := eh.moveStartClusterPos.Add(geom.MakePoint(dx, dy))
+	eh.application.MoveCluster(eh.movingCluster.ID, pos)
+
+	w, h := eh.application.Window.GetFramebufferSize()
+	eh.application.PrepareRenderer(w, h) // only re-uploads the moved cluster
 }
 
 // startPanning starts the panning operation.
@@ -393,16 +506,20 @@ func (eh *EventHandlers) performZoom(zoomDelta float64) {
 func (eh *EventHandlers) handleCreateClusterKey() {
 	// Parse batch count and complexity from input buffer.
 	batchCount, complexity := eh.parseInput("c")
+	overrides := eh.takeOverrides()
 
 	// Always start at the current cursor position for batch creation.
 	startCanvasX, startCanvasY := eh.mouseCanvasX, eh.mouseCanvasY
 
 	// Create clusters in a grid pattern
-	gridUnitPixels := 25.0
+	gridUnitPixels := mesh.DefaultUnitSize
 	gridSpacingX := gridUnitPixels * 20.0 // Horizontal spacing between clusters
 	gridSpacingY := gridUnitPixels * 20.0 // Vertical spacing between clusters
 	gridCols := int(math.Sqrt(float64(batchCount))) + 1
 
+	// Undo batch creations all at once.
+	eh.application.BeginChange()
+	defer eh.application.EndChange()
 	for i := 0; i < batchCount; i++ {
 		// Calculate grid offset with some randomization
 		col, row := i%gridCols, i/gridCols
@@ -410,7 +527,10 @@ func (eh *EventHandlers) handleCreateClusterKey() {
 		offsetY := float64(row)*gridSpacingY + rand.Float64()*gridUnitPixels
 
 		newCanvasX, newCanvasY := startCanvasX+offsetX, startCanvasY+offsetY
-		eh.application.CreateCluster(newCanvasX, newCanvasY, complexity)
+		if err := eh.application.CreateCluster(newCanvasX, newCanvasY, complexity, overrides); err != nil {
+			log.Printf("Failed to create cluster: %v", err)
+			break
+		}
 	}
 
 	w, h := eh.application.Window.GetFramebufferSize()
@@ -421,22 +541,157 @@ func (eh *EventHandlers) handleCreateClusterKey() {
 func (eh *EventHandlers) handleDeleteClusterKey() {
 	batchCount, _ := eh.parseInput("d")
 
-	clusters := eh.application.ClusterManager.FindClosestClusters(eh.mouseCanvasX, eh.mouseCanvasY)
+	if err := eh.application.DeleteClosest(eh.mouseCanvasX, eh.mouseCanvasY, batchCount); err != nil {
+		log.Fatalf("Failed to delete clusters: %v", err)
+	}
+}
+
+// handleTransformKey handles [ and ] (rotate the cluster closest to the mouse
+// counter-clockwise/clockwise) and shift+[ and shift+] (scale it down/up).
+func (eh *EventHandlers) handleTransformKey(forward, scale bool) {
+	clusters := eh.application.ClusterManager.FindClosestClusters(eh.mouseCanvasX, eh.mouseCanvasY, 1)
 	if len(clusters) == 0 {
 		return // nothing to do
 	}
+	cluster := clusters[0]
 
-	if batchCount > len(clusters) {
-		batchCount = len(clusters) // defensive
+	rotation, s := cluster.Rotation, cluster.Scale
+	switch {
+	case scale && forward:
+		s *= scaleStep
+	case scale:
+		s /= scaleStep
+	case forward:
+		rotation += rotationStep
+	default:
+		rotation -= rotationStep
 	}
-	for i := 0; i < batchCount; i++ {
-		clusterID := clusters[i].ID
+	eh.application.TransformCluster(cluster.ID, cluster.CanvasPos, rotation, s)
+
+	w, h := eh.application.Window.GetFramebufferSize()
+	eh.application.PrepareRenderer(w, h) // only updates the cluster's transform
+}
+
+// handleLatticeKey handles G key presses (switch the lattice new clusters are
+// generated on between square and hex).
+func (eh *EventHandlers) handleLatticeKey() {
+	style := &eh.application.Style
+	if style.Lattice == "Hex" {
+		style.Lattice = "Square"
+	} else {
+		style.Lattice = "Hex"
+	}
+	// Keep the symmetry if it's possible on the new lattice.
+	if _, err := parseStyle(style.Lattice, style.Symmetry); err != nil {
+		style.Symmetry = "None"
+	}
+	log.Printf("New clusters use the %s lattice, with %s symmetry", strings.ToLower(style.Lattice), style.Symmetry)
+}
+
+// handleWallpaperKey handles W key presses (switch new clusters between finite
+// patches and periodic wallpapers, repeating across the canvas).
+func (eh *EventHandlers) handleWallpaperKey() {
+	style := &eh.application.Style
+	style.Periodic = !style.Periodic
+	if style.Periodic {
+		log.Printf("New clusters are periodic wallpapers")
+	} else {
+		log.Printf("New clusters are finite patches")
+	}
+}
 
-		if err := eh.application.MemoryController.RemoveCluster(memory.ClusterID(clusterID)); err != nil {
-			log.Fatalf("Failed to remove cluster %d from GPU: %v", clusterID, err)
+// handleUndoKey handles cmd/ctrl+Z (undo) and cmd/ctrl+shift+Z (redo).
+func (eh *EventHandlers) handleUndoKey(redo bool) {
+	var ok bool
+	var err error
+	if redo {
+		ok, err = eh.application.Redo()
+	} else {
+		ok, err = eh.application.Undo()
+	}
+	if err != nil {
+		// The change is left to undo/redo again, and the canvas as it was.
+		log.Printf("Failed to undo/redo: %v", err)
+		return
+	}
+	if !ok {
+		return // nothing to do
+	}
+
+	w, h := eh.application.Window.GetFramebufferSize()
+	eh.application.PrepareRenderer(w, h)
+}
+
+// handleSaveSceneKey handles cmd/ctrl+S (save scene) and cmd/ctrl+shift+S
+// (save scene with embedded geometry, for archival).
+func (eh *EventHandlers) handleSaveSceneKey(embedGeometry bool) {
+	f, err := os.Create(eh.scenePath)
+	if err != nil {
+		log.Printf("Failed to save scene: %v", err)
+		return
+	}
+	if err := eh.application.SaveScene(f, embedGeometry); err != nil {
+		_ = f.Close()
+		log.Printf("Failed to save scene: %v", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		log.Printf("Failed to save scene: %v", err)
+		return
+	}
+	log.Printf("Saved scene to %s", eh.scenePath)
+}
+
+// handleOpenSceneKey handles cmd/ctrl+O (open scene, replacing the canvas).
+func (eh *EventHandlers) handleOpenSceneKey() {
+	if err := loadScene(eh.application, eh.scenePath); err != nil {
+		log.Printf("Failed to open scene: %v", err)
+		return
+	}
+	w, h := eh.application.Window.GetFramebufferSize()
+	eh.application.PrepareRenderer(w, h)
+
+	eh.updateRendererView()
+	mouseX, mouseY := eh.application.Window.GetCursorPos()
+	eh.updateMouseCanvasPos(mouseX, mouseY)
+	log.Printf("Opened scene from %s", eh.scenePath)
+}
+
+// loadScene opens the scene file at path into the application.
+func loadScene(application *app.App, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return application.LoadScene(f)
+}
+
+// handleExportKey handles E and shift+E key presses (export the cluster
+// closest to the mouse, or the whole canvas, as SVG into the working
+// directory).
+func (eh *EventHandlers) handleExportKey(all bool) {
+	cm := eh.application.ClusterManager
+
+	var clusters []*app.Cluster
+	var path string
+	if all {
+		clusters = cm.GetClusters()
+		path = fmt.Sprintf("zellij-canvas-%d.svg", time.Now().Unix())
+	} else {
+		closest := cm.FindClosestClusters(eh.mouseCanvasX, eh.mouseCanvasY, 1)
+		if len(closest) == 0 {
+			return // nothing to do
 		}
-		eh.application.ClusterManager.RemoveCluster(clusterID)
+		clusters = closest[:1]
+		path = fmt.Sprintf("zellij-%d.svg", closest[0].Seed)
+	}
+
+	if err := eh.application.ExportSVG(path, clusters); err != nil {
+		log.Printf("Failed to export SVG: %v", err)
+		return
 	}
+	log.Printf("Exported %d cluster(s) to %s", len(clusters), path)
 }
 
 // handleClusterNavigation handles tab and shift+tab key presses for cluster navigation.
@@ -456,6 +711,48 @@ func (eh *EventHandlers) handleClusterNavigation(next bool) {
 	eh.mouseCanvasY = cluster.CanvasPos.Y
 }
 
+// handlePromptKey handles key presses while the features prompt is open:
+// Enter sets the features typed (see handleChar), Escape cancels, and
+// Backspace deletes the last character.
+func (eh *EventHandlers) handlePromptKey(key glfw.Key) {
+	switch key {
+	case glfw.KeyEnter:
+		eh.prompting = false
+		overrides, err := gen.ParseOverrides(eh.promptBuffer)
+		if err != nil {
+			log.Printf("Invalid features: %v", err)
+			return
+		}
+		eh.overrides = &overrides
+		if spec := overrides.String(); spec != "" {
+			log.Printf("Next cluster has features %s", spec)
+		} else {
+			log.Printf("Next cluster has all its features drawn from its seed")
+		}
+	case glfw.KeyEscape:
+		eh.prompting = false
+	case glfw.KeyBackspace:
+		_, size := utf8.DecodeLastRuneInString(eh.promptBuffer)
+		eh.promptBuffer = eh.promptBuffer[:len(eh.promptBuffer)-size]
+	}
+}
+
+// handleChar handles text input, typed into the features prompt if it's open.
+func (eh *EventHandlers) handleChar(char rune) {
+	if !eh.prompting || char == ':' { // the ':' opening the prompt
+		return
+	}
+	eh.promptBuffer += string(char)
+}
+
+// takeOverrides returns the features set through the prompt for the next
+// cluster, if any, clearing them.
+func (eh *EventHandlers) takeOverrides() *gen.Overrides {
+	overrides := eh.overrides
+	eh.overrides = nil
+	return overrides
+}
+
 func (eh *EventHandlers) parseInput(action string) (count int, complexity *int) {
 	input := eh.inputBuffer
 	if input == "" {
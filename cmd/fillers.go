@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/irfansharif/zellij/data"
+	"github.com/irfansharif/zellij/internal/fillers"
+	"github.com/irfansharif/zellij/internal/gen"
+)
+
+// maxExamples is the number of example compositions listed per unmatched
+// signature.
+const maxExamples = 3
+
+// runFillers implements `zellij fillers`, whose only subcommand is check: it
+// validates the filler library's patterns, and generates compositions across
+// styles (lattices, symmetries, medallions, periodic ones and each focus) to
+// report the tile signatures no pattern fits, e.g.
+//
+//	zellij fillers check
+//	zellij fillers check --seeds 1000 --fillers mine.json
+func runFillers(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("unknown subcommand (want zellij fillers check)")
+	}
+	fs := flag.NewFlagSet("fillers check", flag.ContinueOnError)
+	seeds := fs.Int("seeds", 200, "number of seeds to generate compositions from, per style")
+	fillersFlag := fs.String("fillers", "", fillersUsage)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *seeds < 1 {
+		return fmt.Errorf("invalid number of seeds %d", *seeds)
+	}
+
+	// Validate the built-in library, and every one layered over it.
+	problems, err := fillers.CheckLibrary(bytes.NewReader(data.Fillers))
+	if err != nil {
+		return fmt.Errorf("built-in library: %w", err)
+	}
+	printProblems("built-in library", problems)
+	if *fillersFlag == "" {
+		*fillersFlag = os.Getenv(fillersEnv)
+	}
+	for _, path := range strings.Split(*fillersFlag, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		more, err := checkLibraryFile(path)
+		if err != nil {
+			return err
+		}
+		printProblems(path, more)
+		problems = append(problems, more...)
+	}
+	if err := useFillers(*fillersFlag); err != nil {
+		return err
+	}
+
+	report := checkCoverage(checkStyles(), *seeds)
+	fmt.Printf("%d compositions (%d couldn't be generated), %d tiles, %d unmatched\n",
+		report.compositions, report.failed, report.tiles, report.unmatchedTiles())
+	for _, u := range report.unmatched {
+		var examples []string
+		for _, ex := range u.examples {
+			examples = append(examples, "zellij render "+ex.flags)
+		}
+		fmt.Printf("  %s: %d tiles in %d compositions, e.g. %s\n", u.signature, u.tiles, u.compositions, strings.Join(examples, "; "))
+	}
+
+	if len(problems) > 0 || len(report.unmatched) > 0 {
+		return fmt.Errorf("%d problems with patterns, %d signatures unmatched", len(problems), len(report.unmatched))
+	}
+	return nil
+}
+
+// checkLibraryFile validates the filler library at path.
+func checkLibraryFile(path string) ([]error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	problems, err := fillers.CheckLibrary(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return problems, nil
+}
+
+// printProblems prints the problems with the named library's patterns.
+func printProblems(name string, problems []error) {
+	fmt.Printf("%s: %d problems\n", name, len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  %v\n", problem)
+	}
+}
+
+// checkStyle is a style compositions are generated in, and the flags to
+// render one with.
+type checkStyle struct {
+	style gen.Style
+	flags string
+}
+
+// checkStyles returns the styles to generate compositions in: every
+// symmetry, as a medallion or not, and periodic compositions, on both
+// lattices, and compositions with each focus.
+func checkStyles() []checkStyle {
+	var styles []checkStyle
+	for _, lattice := range []string{"Square", "Hex"} {
+		flags := "--lattice " + strings.ToLower(lattice)
+		for _, symmetry := range gen.Symmetries(lattice) {
+			symFlags := flags + " --symmetry " + strings.ToLower(symmetry)
+			styles = append(styles,
+				checkStyle{gen.Style{Lattice: lattice, Symmetry: symmetry}, symFlags},
+				checkStyle{gen.Style{Lattice: lattice, Symmetry: symmetry, Medallion: true}, symFlags + " --medallion"},
+				checkStyle{gen.Style{Lattice: lattice, Symmetry: symmetry, Periodic: true}, symFlags + " --periodic"})
+		}
+		for _, focus := range gen.Foci(lattice) {
+			if focus == "None" {
+				continue
+			}
+			o := gen.Overrides{Foci: []gen.Focus{{Motif: focus}}}
+			styles = append(styles, checkStyle{gen.Style{Lattice: lattice, Overrides: o}, flags + " --features " + o.String()})
+		}
+	}
+	return slices.DeleteFunc(styles, func(s checkStyle) bool { return s.style.Validate() != nil })
+}
+
+// coverage is what checkCoverage finds.
+type coverage struct {
+	compositions, failed, tiles int
+	unmatched                   []*unmatched // by tiles, descending
+}
+
+// unmatched is a tile signature no pattern fits, see fillers.TileSignature.
+type unmatched struct {
+	signature           string
+	tiles, compositions int
+	examples            []example // the first compositions it's in
+}
+
+// example is a composition, the n-th generated, to render with flags.
+type example struct {
+	n     int
+	flags string
+}
+
+func (c coverage) unmatchedTiles() int {
+	n := 0
+	for _, u := range c.unmatched {
+		n += u.tiles
+	}
+	return n
+}
+
+// checkCoverage generates compositions from the first seeds in each style,
+// and matches their tiles against the current filler library.
+func checkCoverage(styles []checkStyle, seeds int) coverage {
+	type job struct {
+		n     int
+		style checkStyle
+		seed  int64
+	}
+	jobs := make(chan job)
+	go func() {
+		n := 0
+		for _, style := range styles {
+			for seed := int64(1); seed <= int64(seeds); seed++ {
+				jobs <- job{n, style, seed}
+				n++
+			}
+		}
+		close(jobs)
+	}()
+
+	var mu sync.Mutex
+	var report coverage
+	bySig := make(map[string]*unmatched)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lib := fillers.Current()
+			for j := range jobs {
+				f, err := gen.FeaturesFor(j.seed, nil, j.style.style)
+				var comp gen.Composition
+				if err == nil {
+					comp, err = gen.Generate(j.seed, f)
+				}
+				counts := make(map[string]int)
+				for _, tile := range comp.Tiles {
+					if _, _, ok := lib.Match(tile.Path, fillers.Tile{}, fillers.Fixed); !ok {
+						counts[fillers.TileSignature(tile.Path)]++
+					}
+				}
+
+				mu.Lock()
+				report.compositions++
+				if err != nil {
+					report.failed++
+				}
+				report.tiles += len(comp.Tiles)
+				for sig, n := range counts {
+					u := bySig[sig]
+					if u == nil {
+						u = &unmatched{signature: sig}
+						bySig[sig] = u
+						report.unmatched = append(report.unmatched, u)
+					}
+					u.tiles += n
+					u.compositions++
+					// Workers finish out of order, keep the first examples.
+					u.examples = append(u.examples, example{j.n, fmt.Sprintf("--seed %d %s", j.seed, j.style.flags)})
+					slices.SortFunc(u.examples, func(a, b example) int { return a.n - b.n })
+					u.examples = u.examples[:min(len(u.examples), maxExamples)]
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(report.unmatched, func(a, b *unmatched) int {
+		if a.tiles != b.tiles {
+			return b.tiles - a.tiles
+		}
+		return strings.Compare(a.signature, b.signature)
+	})
+	return report
+}
@@ -1,21 +1,25 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
+	"math"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
 
 	"github.com/irfansharif/zellij/internal/app"
+	"github.com/irfansharif/zellij/internal/fillers"
 	"github.com/irfansharif/zellij/internal/gen"
+	"github.com/irfansharif/zellij/internal/geom"
 	"github.com/irfansharif/zellij/internal/memory"
 	"github.com/irfansharif/zellij/internal/render"
 )
@@ -49,7 +53,74 @@ func makeTitle(fps float64, avgFrameTime float64, renderStats render.Stats, memS
 }
 
 func main() {
+	// Subcommands.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "render":
+			if err := runRender(os.Args[2:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return
+				}
+				log.Fatalf("render: %v", err)
+			}
+			return
+		case "fillers":
+			if err := runFillers(os.Args[2:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return
+				}
+				log.Fatalf("fillers: %v", err)
+			}
+			return
+		}
+	}
+
+	var seeds seedRange
+	flag.Var(&seeds, "seed", "seed for the initial cluster (defaults to $"+seedEnv+", else the current time)")
+	scenePath := flag.String("scene", defaultScenePath, "scene file to open on startup (if it exists), and to save to/open from with Cmd/Ctrl+S and Cmd/Ctrl+O")
+	latticeFlag := flag.String("lattice", "square", "lattice to generate new clusters on: square or hex (toggled with G)")
+	symmetryFlag := flag.String("symmetry", "none", "symmetry of new clusters: none, d1 or d2, c4 or d4 (square lattice), c6 or d6 (hex lattice)")
+	medallion := flag.Bool("medallion", false, "always center a star in new clusters")
+	periodic := flag.Bool("periodic", false, "generate new clusters as periodic wallpapers, repeating across the canvas (toggled with W)")
+	maskFlag := flag.String("mask", "", "shape to clip new clusters to: "+strings.Join(gen.MaskNames, ", ")+", or a polygon as x0,y0,x1,y1,... within [-1,1]²")
+	featuresFlag := flag.String("features", "", "features to set on new clusters instead of drawing them from the seed, e.g. density=12,lines=30,focus=sixteen,shimmer=3 (also set with :, see README)")
+	fillersFlag := flag.String("fillers", "", fillersUsage)
+	variantsFlag := flag.String("variants", "fixed", variantsUsage)
+	fallbackFlag := flag.String("fallback", fillers.DefaultFallback, fallbackUsage)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  zellij [flags]\n  zellij render [flags] (see zellij render -h)\n  zellij fillers check [flags] (see zellij fillers check -h)\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
+	if err := seeds.setDefault(); err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	if seeds.Len() > 1 {
+		log.Fatalf("Invalid -seed value %q: ranges are only supported by zellij render", seeds.String())
+	}
+	style, err := parseStyle(*latticeFlag, *symmetryFlag)
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	style.Medallion, style.Periodic = *medallion, *periodic
+	if style.Mask, err = parseMask(*maskFlag); err != nil {
+		log.Fatalf("Invalid -mask value: %v", err)
+	}
+	if style.Overrides, err = gen.ParseOverrides(*featuresFlag); err != nil {
+		log.Fatalf("Invalid -features value: %v", err)
+	}
+	if err := style.Validate(); err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	if err := useFillers(*fillersFlag); err != nil {
+		log.Fatalf("Invalid -fillers value: %v", err)
+	}
+	if err := useVariants(*variantsFlag); err != nil {
+		log.Fatalf("Invalid -variants value: %v", err)
+	}
+	if err := useFallback(*fallbackFlag); err != nil {
+		log.Fatalf("Invalid -fallback value: %v", err)
+	}
 
 	if err := glfw.Init(); err != nil {
 		log.Fatalf("Failed to initialize GLFW: %v", err)
@@ -79,25 +150,32 @@ func main() {
 		log.Fatalf("Failed to initialize OpenGL: %v", err)
 	}
 
-	s := seed()
-	generator := gen.NewGenerator()
-	generator.SetFeaturesForComplexity(rand.New(rand.NewSource(s)), nil /* complexity */)
+	s := seeds.first
 
 	cw, ch := window.GetFramebufferSize()
 	application := app.NewApp(
 		window,
-		generator,
 		app.NewView(cw, ch),
 		s,
 	)
+	application.Style = style
 
-	// Create initial cluster manually.
-	centerX, centerY := float64(cw)/2.0, float64(ch)/2.0 // center of the canvas
-	application.CreateCluster(centerX, centerY, nil /* complexity */)
+	// Open the scene if there's one, otherwise create initial cluster
+	// manually.
+	if _, err := os.Stat(*scenePath); err == nil {
+		if err := loadScene(application, *scenePath); err != nil {
+			log.Fatalf("Failed to open scene: %v", err)
+		}
+	} else {
+		centerX, centerY := float64(cw)/2.0, float64(ch)/2.0 // center of the canvas
+		if err := application.CreateCluster(centerX, centerY, nil /* complexity */, nil /* overrides */); err != nil {
+			log.Fatalf("Failed to create cluster: %v", err)
+		}
+	}
 	application.PrepareRenderer(cw, ch)
 
 	// Initialize event handlers.
-	eventHandlers := NewEventHandlers(application)
+	eventHandlers := NewEventHandlers(application, *scenePath)
 
 	frameCount, frameTimeSum := 0, 0.0
 	lastFPSUpdate := time.Now()
@@ -114,6 +192,7 @@ func main() {
 		gl.ClearColor(1, 1, 1, 1)
 		gl.Clear(gl.COLOR_BUFFER_BIT)
 
+		application.Update(w, h) // pick up work finished in the background
 		application.Renderer.Draw()
 		application.Window.SwapBuffers()
 		glfw.PollEvents()
@@ -162,15 +241,187 @@ func main() {
 	}
 }
 
-func seed() int64 {
-	seedStr := os.Getenv("ZELLIJ_SEED")
+// seedRange is a flag.Value for a single seed ("42") or an inclusive range of
+// seeds ("1..500"). If never set, it defaults to seedEnv's, else the current
+// time; see setDefault.
+type seedRange struct {
+	first, last int64
+	set         bool
+}
+
+var _ flag.Value = (*seedRange)(nil)
+
+func (s *seedRange) String() string {
+	if s == nil || !s.set {
+		return ""
+	}
+	if s.first == s.last {
+		return strconv.FormatInt(s.first, 10)
+	}
+	return fmt.Sprintf("%d..%d", s.first, s.last)
+}
+
+func (s *seedRange) Set(v string) error {
+	firstStr, lastStr, isRange := strings.Cut(v, "..")
+	first, err := strconv.ParseInt(firstStr, 10, 64)
+	if err != nil {
+		return err
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.ParseInt(lastStr, 10, 64); err != nil {
+			return err
+		}
+		if last < first {
+			return fmt.Errorf("empty seed range %d..%d", first, last)
+		}
+	}
+	s.first, s.last, s.set = first, last, true
+	return nil
+}
+
+// Len returns the number of seeds in the range, or math.MaxInt64 for ranges
+// with more.
+func (s *seedRange) Len() int64 {
+	n := uint64(s.last-s.first) + 1 // wraps around as int64, not as uint64
+	if n == 0 || n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+// seedEnv is the environment variable with the seed (or range of seeds) to
+// use, if -seed isn't set.
+const seedEnv = "ZELLIJ_SEED"
+
+// setDefault sets the range to the seeds in seedEnv, or else just the current
+// time, if it wasn't set.
+func (s *seedRange) setDefault() error {
+	if s.set {
+		return nil
+	}
+	if v := os.Getenv(seedEnv); v != "" {
+		if err := s.Set(v); err != nil {
+			return fmt.Errorf("invalid %s value %q: %w", seedEnv, v, err)
+		}
+		return nil
+	}
 	now := time.Now().Unix()
-	if seedStr == "" {
-		return now
+	s.first, s.last = now, now
+	return nil
+}
+
+// parseStyle parses the --lattice and --symmetry flags (case-insensitively),
+// checking that the symmetry is possible on the lattice.
+func parseStyle(lattice, symmetry string) (gen.Style, error) {
+	var style gen.Style
+	switch strings.ToLower(lattice) {
+	case "square":
+		style.Lattice = "Square"
+	case "hex":
+		style.Lattice = "Hex"
+	default:
+		return gen.Style{}, fmt.Errorf("unknown lattice %q (want square or hex)", lattice)
+	}
+
+	symmetries := gen.Symmetries(style.Lattice)
+	for _, s := range symmetries {
+		if strings.EqualFold(symmetry, s) {
+			style.Symmetry = s
+			return style, nil
+		}
 	}
-	seed, err := strconv.ParseInt(seedStr, 10, 64)
+	if strings.EqualFold(symmetry, "D8") {
+		return gen.Style{}, fmt.Errorf("8-fold symmetry isn't possible on either lattice, try d4 (square) or d6 (hex)")
+	}
+	return gen.Style{}, fmt.Errorf("unknown symmetry %q for the %s lattice (want one of %s)",
+		symmetry, strings.ToLower(style.Lattice), strings.ToLower(strings.Join(symmetries, ", ")))
+}
+
+// parseMask parses a mask: a built-in mask's name (see gen.BuiltinMask), or a
+// polygon as comma-separated x,y pairs in normalized grid coordinates. Returns
+// nil for no mask ("" or "none").
+func parseMask(v string) ([]geom.Point, error) {
+	if v == "" || strings.EqualFold(v, "none") {
+		return nil, nil
+	}
+	if mask, ok := gen.BuiltinMask(v); ok {
+		return mask, nil
+	}
+
+	fields := strings.Split(v, ",")
+	if len(fields) < 6 || len(fields)%2 != 0 {
+		return nil, fmt.Errorf("unknown mask %q (want one of %s, or a polygon as x0,y0,x1,y1,... with at least three points)",
+			v, strings.Join(gen.MaskNames, ", "))
+	}
+	mask := make([]geom.Point, len(fields)/2)
+	for i := range mask {
+		x, err := strconv.ParseFloat(strings.TrimSpace(fields[2*i]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mask point %d: %w", i, err)
+		}
+		y, err := strconv.ParseFloat(strings.TrimSpace(fields[2*i+1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mask point %d: %w", i, err)
+		}
+		mask[i] = geom.MakePoint(x, y)
+	}
+	return mask, nil
+}
+
+// fillersUsage is the help for the -fillers flag.
+const fillersUsage = "filler library files (JSON, comma-separated) to layer over the built-in one, each replacing the patterns for the signatures it has (defaults to $" + fillersEnv + ")"
+
+// fillersEnv is the environment variable with the filler library files to
+// use, if -fillers isn't set.
+const fillersEnv = "ZELLIJ_FILLERS"
+
+// useFillers fills tiles from the built-in filler library, with the given
+// comma-separated library files (or else those in fillersEnv) layered over
+// it.
+func useFillers(files string) error {
+	if files == "" {
+		files = os.Getenv(fillersEnv)
+	}
+	lib := fillers.Default()
+	for _, path := range strings.Split(files, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		top, err := fillers.LoadLibraryFile(path)
+		if err != nil {
+			return err
+		}
+		lib = lib.Layer(top)
+	}
+	fillers.Use(lib)
+	return nil
+}
+
+// variantsUsage is the help for the -variants flag.
+const variantsUsage = "how tiles several filler patterns fit pick one: fixed (the same for every tile with a signature), cluster (the same per cluster), tile (per tile) or symmetric (per tile, keeping symmetric clusters symmetric)"
+
+// useVariants picks filler patterns with the named selector, see
+// fillers.Selectors.
+func useVariants(name string) error {
+	sel, err := fillers.ParseSelector(name)
+	if err != nil {
+		return err
+	}
+	fillers.UseSelector(sel)
+	return nil
+}
+
+// fallbackUsage is the help for the -fallback flag.
+const fallbackUsage = "how to fill tiles no filler pattern fits: none (leaving a gap), plain (in an accent colour), strapwork (a star touching their edges' midpoints) or debug (in magenta)"
+
+// useFallback fills tiles no filler pattern fits with the named fallback, see
+// fillers.Fallbacks.
+func useFallback(name string) error {
+	fallback, err := fillers.ParseFallback(name)
 	if err != nil {
-		log.Fatalf("Invalid ZELLIJ_SEED value '%s': %v", seedStr, err)
+		return err
 	}
-	return seed
+	fillers.UseFallback(fallback)
+	return nil
 }
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/irfansharif/zellij/internal/app"
+	"github.com/irfansharif/zellij/internal/export"
+	"github.com/irfansharif/zellij/internal/fillers"
+	"github.com/irfansharif/zellij/internal/gen"
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/mesh"
+)
+
+// seedPlaceholder, if present in the output path, is replaced by each
+// rendered seed.
+const seedPlaceholder = "{seed}"
+
+// runRender implements `zellij render`: it generates one composition per seed
+// and writes each out through an offscreen path (no window or OpenGL context),
+// e.g.
+//
+//	zellij render --seed 42 --complexity 20 --size 4096 --format png -o out.png
+//	zellij render --seed 1..500 -o catalogue/{seed}.svg
+//	zellij render --seed 7 --lattice hex --symmetry d6 --medallion -o hex.png
+//	zellij render --seed 7 --periodic -o wallpaper.png
+//	zellij render --seed 7 --mask horseshoe -o arch.png
+//	zellij render --seed 7 --features density=12,lines=30,focus=sixteen -o star.png
+//	zellij render --seed 7 --lines design.lines -o design.png
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	var seeds seedRange
+	fs.Var(&seeds, "seed", "seed, or inclusive range of seeds (e.g. 1..500), to render (defaults to $"+seedEnv+", else the current time)")
+	complexity := fs.Int("complexity", 0, "complexity level (0 for default randomization)")
+	latticeFlag := fs.String("lattice", "square", "lattice to generate on: square or hex")
+	symmetryFlag := fs.String("symmetry", "none", "symmetry: none, d1 or d2, c4 or d4 (square lattice), c6 or d6 (hex lattice)")
+	medallion := fs.Bool("medallion", false, "always center a star")
+	periodic := fs.Bool("periodic", false, "generate a periodic wallpaper, repeated to fill the output")
+	maskFlag := fs.String("mask", "", "shape to clip to: "+strings.Join(gen.MaskNames, ", ")+", or a polygon as x0,y0,x1,y1,... within [-1,1]²")
+	featuresFlag := fs.String("features", "", "features to set instead of drawing them from the seed, e.g. density=12,lines=30,focus=sixteen,shimmer=3")
+	linesFlag := fs.String("lines", "", "line set file (text or JSON, see README) to trace instead of generating from the seed, which then only picks the colors")
+	fillersFlag := fs.String("fillers", "", fillersUsage)
+	variantsFlag := fs.String("variants", "fixed", variantsUsage)
+	fallbackFlag := fs.String("fallback", fillers.DefaultFallback, fallbackUsage)
+	size := fs.Int("size", 2048, "output size in pixels (PNG; SVGs are sized in world units)")
+	format := fs.String("format", "", "output format: png or svg (defaults to the output path's extension, else png)")
+	output := fs.String("o", "zellij-"+seedPlaceholder, "output path; "+seedPlaceholder+" is replaced by the seed, and is appended when rendering multiple seeds")
+	transparent := fs.Bool("transparent", false, "use a transparent background (PNG)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := seeds.setDefault(); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *size <= 0 {
+		return fmt.Errorf("invalid size %d", *size)
+	}
+	style, err := parseStyle(*latticeFlag, *symmetryFlag)
+	if err != nil {
+		return err
+	}
+	style.Medallion, style.Periodic = *medallion, *periodic
+	if style.Mask, err = parseMask(*maskFlag); err != nil {
+		return err
+	}
+	if style.Overrides, err = gen.ParseOverrides(*featuresFlag); err != nil {
+		return err
+	}
+	if err := style.Validate(); err != nil {
+		return err
+	}
+	if err := useFillers(*fillersFlag); err != nil {
+		return err
+	}
+	if err := useVariants(*variantsFlag); err != nil {
+		return err
+	}
+	if err := useFallback(*fallbackFlag); err != nil {
+		return err
+	}
+	var lines *gen.LineSet
+	if *linesFlag != "" {
+		var generated []string
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "complexity", "lattice", "symmetry", "medallion", "periodic", "mask", "features":
+				generated = append(generated, "--"+f.Name)
+			}
+		})
+		if len(generated) > 0 {
+			return fmt.Errorf("%s can't be set with --lines, the line set has the lines to trace", strings.Join(generated, ", "))
+		}
+		if lines, err = loadLineSet(*linesFlag); err != nil {
+			return err
+		}
+	}
+
+	ext := strings.TrimPrefix(filepath.Ext(*output), ".")
+	if ext != "" && ext != "png" && ext != "svg" {
+		return fmt.Errorf("unknown output extension .%s (want .png, .svg, or none to use --format's)", ext)
+	}
+	if *format == "" {
+		*format = "png"
+		if ext != "" {
+			*format = ext
+		}
+	}
+	if *format != "png" && *format != "svg" {
+		return fmt.Errorf("unknown format %q (want png or svg)", *format)
+	}
+	if ext != "" && ext != *format {
+		return fmt.Errorf("output %s is a .%s, but --format is %s", *output, ext, *format)
+	}
+
+	var c *int
+	if *complexity > 0 {
+		c = complexity
+	}
+
+	skipped := 0
+	for seed, done := seeds.first, false; !done; seed++ {
+		done = seed == seeds.last // not seed > last, which overflows at math.MaxInt64
+		var cluster *app.Cluster
+		if lines != nil {
+			cluster, err = app.ClusterFromLines(*lines, seed, geom.MakePoint(0, 0))
+		} else {
+			cluster, err = app.GenerateCluster(seed, c, style, geom.MakePoint(0, 0))
+		}
+		if err != nil && lines != nil {
+			return fmt.Errorf("%s: %w", *linesFlag, err) // the same for every seed
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "seed %d: %v, skipping\n", seed, err)
+			skipped++
+			continue
+		}
+
+		path := renderPath(*output, *format, seed, seeds.Len() > 1)
+		if err := renderTo(path, *format, cluster.Mesh(), *size, *transparent); err != nil {
+			return fmt.Errorf("seed %d: %w", seed, err)
+		}
+		fmt.Println(path)
+	}
+	if skipped > 0 {
+		return fmt.Errorf("%d of %d seeds skipped, see above", skipped, seeds.Len())
+	}
+	return nil
+}
+
+// loadLineSet reads the line set at path, and checks it's possible.
+func loadLineSet(path string) (*gen.LineSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	set, err := gen.LoadLineSet(f)
+	if err == nil {
+		err = set.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &set, nil
+}
+
+// renderPath computes the output path for the given seed. The output path's
+// extension, if any, is the format's.
+func renderPath(output, format string, seed int64, multi bool) string {
+	seedStr := strconv.FormatInt(seed, 10)
+	base := strings.TrimSuffix(output, "."+format)
+	if strings.Contains(base, seedPlaceholder) {
+		base = strings.ReplaceAll(base, seedPlaceholder, seedStr)
+	} else if multi {
+		base += "-" + seedStr
+	}
+	return base + "." + format
+}
+
+// renderTo writes a single cluster to path in the given format.
+func renderTo(path, format string, cluster mesh.Cluster, size int, transparent bool) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	clusters := []mesh.Cluster{cluster}
+	switch format {
+	case "svg":
+		err = export.SVG(f, clusters)
+	default:
+		background := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+		if transparent {
+			background = color.RGBA{}
+		}
+		err = export.PNG(f, clusters, export.PNGOptions{
+			Width:      size,
+			Height:     size,
+			Fit:        true,
+			Background: background,
+		})
+	}
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,10 @@
+// Package data embeds the data files zellij ships with, so the binary doesn't
+// depend on the directory it's run from.
+package data
+
+import _ "embed"
+
+// Fillers is the default filler library, as JSON; see fillers.Default.
+//
+//go:embed fillers.json
+var Fillers []byte
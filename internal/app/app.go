@@ -1,69 +1,112 @@
 package app
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	"math/rand"
+	"os"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/irfansharif/zellij/internal/export"
 	"github.com/irfansharif/zellij/internal/gen"
 	"github.com/irfansharif/zellij/internal/geom"
 	"github.com/irfansharif/zellij/internal/memory"
-	"github.com/irfansharif/zellij/internal/palette"
+	"github.com/irfansharif/zellij/internal/mesh"
 	"github.com/irfansharif/zellij/internal/render"
+	"github.com/irfansharif/zellij/internal/worker"
 )
 
-const maxGenerationAttempts = 10 // maximum number of attempts to generate a valid composition
-const integerGridSize = 25.0 // grid size in integer space (using the same one makes individual tiles size identically)
+const maxGenerationAttempts = 10  // maximum number of attempts to generate a valid composition
+const maxClusterAtCandidates = 16 // number of closest clusters considered when looking for one under a point
 
 // App encapsulates the main application state and logic.
 type App struct {
 	Window           *glfw.Window
 	Renderer         *render.Renderer
-	Generator        *gen.Generator
 	View             *View
 	ClusterManager   *ClusterManager
 	MemoryController *memory.MemoryController
+	History          *History
+
+	// Style new clusters are generated with (lattice, symmetry, etc.).
+	// Existing clusters keep theirs when regenerated.
+	Style gen.Style
+
+	// Compositions are generated in the background, see Update.
+	generations *worker.Pool[memory.ClusterID, generation]
+}
+
+// generation is a composition generated in the background.
+type generation struct {
+	pending *pendingComposition // what it was generated for
+	comp    gen.Composition
+	err     error
 }
 
 // NewApp creates a new application instance.
-func NewApp(window *glfw.Window, generator *gen.Generator, view *View, seed int64) *App {
+func NewApp(window *glfw.Window, view *View, seed int64) *App {
 	memController := memory.NewMemoryController()
 	renderer := render.NewRenderer(memController)
 	clusterManager := NewClusterManager(seed)
 	return &App{
 		Window:           window,
 		Renderer:         renderer,
-		Generator:        generator,
 		View:             view,
 		ClusterManager:   clusterManager,
 		MemoryController: memController,
+		History:          &History{},
+		generations:      worker.NewPool[memory.ClusterID, generation](0),
 	}
 }
 
-// CreateCluster creates a new cluster at the specified position.
-func (app *App) CreateCluster(canvasX, canvasY float64, complexity *int) {
-	seed := app.ClusterManager.IncrementSeed()
-
-	// Generate composition for this cluster.
-	comp, ok := app.GenerateComposition(seed, complexity)
-	if !ok {
-		log.Printf("Failed to generate valid composition after %d attempts", maxGenerationAttempts)
-		return // don't create the cluster
+// CreateCluster creates a new cluster at the specified position. Its
+// composition is generated in the background; it's drawn as a placeholder
+// until then, and removed if generation fails. If overrides is set, it's used
+// instead of the app's style's overrides. Returns an error, creating nothing,
+// if the style's not possible with them.
+func (app *App) CreateCluster(canvasX, canvasY float64, complexity *int, overrides *gen.Overrides) error {
+	style := app.Style
+	if overrides != nil {
+		style.Overrides = *overrides
+	}
+	if err := style.Validate(); err != nil {
+		return err
 	}
+	seed := app.ClusterManager.IncrementSeed()
 
 	// Add cluster at specified position.
+	app.BeginChange()
+	defer app.EndChange()
+	app.touch(app.ClusterManager.nextID)
 	canvasPos := geom.MakePoint(canvasX, canvasY)
-	gridBounds := geom.MakeBox(0, 0, integerGridSize, integerGridSize)
-	app.ClusterManager.AddCluster(gridBounds, canvasPos, comp, seed, complexity)
+	cluster := app.ClusterManager.AddCluster(canvasPos, gen.Composition{Style: style}, seed, complexity)
+	app.generate(cluster, style)
+	return nil
 }
 
-// RegenerateClosest regenerates the closest cluster to the given center.
-func (app *App) RegenerateClosest(centerX, centerY float64, complexity *int) {
-	clusters := app.ClusterManager.FindClosestClusters(centerX, centerY)
+// RegenerateClosest regenerates the closest cluster to the given center, after
+// stepping its seed by seedDelta. The new composition is generated in the
+// background, the cluster keeps its current one until then. If overrides is
+// set, it replaces the cluster's style's overrides. Returns an error, leaving
+// the cluster as is, if its style's not possible with them.
+func (app *App) RegenerateClosest(centerX, centerY float64, seedDelta int64, complexity *int, overrides *gen.Overrides) error {
+	clusters := app.ClusterManager.FindClosestClusters(centerX, centerY, 1)
 	if len(clusters) == 0 {
-		return // nothing to do
+		return nil // nothing to do
 	}
 	cluster := clusters[0]
+	style := cluster.Style()
+	if overrides != nil {
+		style.Overrides = *overrides
+	}
+	if err := style.Validate(); err != nil {
+		return err
+	}
+
+	app.BeginChange()
+	defer app.EndChange()
+	app.touch(cluster.ID)
+	cluster.SetSeed(cluster.Seed + seedDelta)
 
 	// Determine complexity to use: parameter if provided, otherwise cluster's
 	// existing complexity.
@@ -73,12 +116,151 @@ func (app *App) RegenerateClosest(centerX, centerY float64, complexity *int) {
 
 	// Regenerate cluster from scratch with the cluster's seed (retrying
 	// internally if needed).
-	comp, ok := app.GenerateComposition(cluster.Seed, complexity)
+	cluster.SetComplexity(complexity)
+	app.generate(cluster, style)
+	return nil
+}
+
+// generate starts generating a new composition for the cluster in the given
+// style, from its seed and complexity.
+func (app *App) generate(cluster *Cluster, style gen.Style) {
+	cluster.pending = &pendingComposition{style: style}
+	app.submit(cluster)
+}
+
+// submit queues up generating the cluster's pending composition, superseding
+// whatever was queued for it before.
+func (app *App) submit(cluster *Cluster) {
+	p, seed, complexity, style := cluster.pending, cluster.Seed, cluster.Complexity, cluster.pending.style
+	app.generations.Submit(cluster.ID, func() generation {
+		comp, err := GenerateComposition(seed, complexity, style)
+		return generation{pending: p, comp: comp, err: err}
+	})
+}
+
+// Update applies compositions generated in the background since the last
+// call, and uploads geometry built for them. It's meant to be called once per
+// frame, before drawing.
+func (app *App) Update(cw, ch int) {
+	if app.finishGenerated() && cw > 0 && ch > 0 { // otherwise they're left dirty, for later
+		app.PrepareRenderer(cw, ch)
+	}
+	app.Renderer.UploadFinished()
+}
+
+// finishGenerated applies compositions generated in the background since the
+// last call, returning whether there were any.
+func (app *App) finishGenerated() bool {
+	finished := false
+	for {
+		result, ok := app.generations.Next()
+		if !ok {
+			break
+		}
+		g := result.Value
+		g.pending.done, g.pending.comp, g.pending.err = true, g.comp, g.err
+
+		cluster, ok := app.ClusterManager.clusters[result.Key]
+		if !ok || cluster.pending != g.pending {
+			continue // superseded, deleted or undone; kept around for redo
+		}
+		app.finish(cluster)
+		finished = true
+	}
+	return finished
+}
+
+// finish applies the cluster's pending composition, once done. Clusters that
+// don't have a composition to fall back to are removed if generation failed,
+// and not recorded in the history: they were never really created.
+func (app *App) finish(cluster *Cluster) {
+	p := cluster.pending
+	cluster.pending = nil
+	if p.err == nil {
+		cluster.SetComposition(p.comp)
+		return
+	}
+
+	log.Printf("Failed to generate composition for cluster %d: %v", cluster.ID, p.err)
+	if HasValidGeometry(cluster.Composition) {
+		return // keep the current one
+	}
+	if app.MemoryController.HasCluster(cluster.ID) {
+		if err := app.MemoryController.RemoveCluster(cluster.ID); err != nil {
+			log.Printf("Failed to remove cluster %d from GPU: %v", cluster.ID, err)
+		}
+	}
+	app.ClusterManager.RemoveCluster(cluster.ID)
+}
+
+// ClusterAt returns the cluster under the given canvas position, or nil if
+// there's none. If clusters overlap, the one centered closest wins.
+func (app *App) ClusterAt(canvasX, canvasY float64) *Cluster {
+	for _, cluster := range app.ClusterManager.FindClosestClusters(canvasX, canvasY, maxClusterAtCandidates) {
+		bounds, err := mesh.WorldBounds(cluster.Mesh())
+		if err != nil {
+			continue
+		}
+		if canvasX >= bounds.X && canvasX <= bounds.X+bounds.W &&
+			canvasY >= bounds.Y && canvasY <= bounds.Y+bounds.H {
+			return cluster
+		}
+	}
+	return nil
+}
+
+// DeleteClosest deletes up to n clusters closest to the given center.
+func (app *App) DeleteClosest(centerX, centerY float64, n int) error {
+	clusters := app.ClusterManager.FindClosestClusters(centerX, centerY, n)
+
+	app.BeginChange()
+	defer app.EndChange()
+	for _, cluster := range clusters {
+		if err := app.DeleteCluster(cluster.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteCluster deletes the cluster with the given ID, releasing its GPU
+// memory.
+func (app *App) DeleteCluster(id memory.ClusterID) error {
+	app.BeginChange()
+	defer app.EndChange()
+	app.touch(id)
+
+	if app.MemoryController.HasCluster(id) {
+		if err := app.MemoryController.RemoveCluster(id); err != nil {
+			return fmt.Errorf("failed to remove cluster %d from GPU: %w", id, err)
+		}
+	}
+	app.ClusterManager.RemoveCluster(id)
+	return nil
+}
+
+// MoveCluster moves the cluster with the given ID to the given canvas
+// position.
+func (app *App) MoveCluster(id memory.ClusterID, canvasPos geom.Point) {
+	cluster, ok := app.ClusterManager.clusters[id]
 	if !ok {
-		return // don't update the cluster with invalid geometry
+		return // nothing to do
 	}
-	cluster.SetComposition(comp)
-	cluster.SetComplexity(complexity)
+	app.TransformCluster(id, canvasPos, cluster.Rotation, cluster.Scale)
+}
+
+// TransformCluster sets the canvas position, rotation (radians) and scale of
+// the cluster with the given ID. Only the cluster's transform is updated on
+// the GPU, its geometry isn't regenerated.
+func (app *App) TransformCluster(id memory.ClusterID, canvasPos geom.Point, rotation, scale float64) {
+	if _, ok := app.ClusterManager.clusters[id]; !ok {
+		return // nothing to do
+	}
+
+	app.BeginChange()
+	defer app.EndChange()
+	app.touch(id)
+	app.ClusterManager.TransformCluster(id, canvasPos, rotation, scale)
 }
 
 // PrepareRenderer prepares the renderer with all current clusters.
@@ -90,15 +272,17 @@ func (app *App) PrepareRenderer(cw, ch int) {
 	clusters := app.ClusterManager.GetClusters()
 	renderData := make([]render.ClusterRenderData, len(clusters))
 	for i, cluster := range clusters {
-		palette := palette.RandomPalette(rand.New(rand.NewSource(cluster.Seed)))
 		renderData[i] = render.ClusterRenderData{
 			ID:          cluster.ID,
 			Composition: cluster.Composition,
-			GridBounds:  cluster.GridBounds,
+			UnitSize:    cluster.UnitSize,
 			CanvasPos:   cluster.CanvasPos,
-			Palette:     palette,
+			Palette:     cluster.Palette(),
 			Seed:        cluster.Seed,
+			Rotation:    cluster.Rotation,
+			Scale:       cluster.Scale,
 			Dirty:       cluster.Dirty,
+			Pending:     cluster.Pending() && !HasValidGeometry(cluster.Composition),
 		}
 	}
 	if err := app.Renderer.PrepareMulti(renderData, cw, ch); err != nil {
@@ -109,24 +293,103 @@ func (app *App) PrepareRenderer(cw, ch int) {
 	}
 }
 
-// GenerateComposition generates a composition with the given base seed,
-// retrying up to maxRetries times until a valid geometry is produced.
-func (app *App) GenerateComposition(baseSeed int64, complexity *int) (gen.Composition, bool) {
-	for attempt := 0; attempt < maxGenerationAttempts; attempt++ {
-		retrySeed := baseSeed + int64(attempt)
-		comp := app.Generator.Generate(retrySeed, complexity)
+// ExportSVG writes the given clusters, at their canvas positions, as an SVG
+// document to the given path. Clusters still waiting on their first
+// composition are left out.
+func (app *App) ExportSVG(path string, clusters []*Cluster) error {
+	meshes := make([]mesh.Cluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		if !HasValidGeometry(cluster.Composition) {
+			continue // nothing to export (yet)
+		}
+		meshes = append(meshes, cluster.Mesh())
+	}
+	if len(meshes) == 0 {
+		return fmt.Errorf("no clusters to export")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := export.SVG(f, meshes); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// GenerateCluster generates a standalone cluster, not tracked by any cluster
+// manager, in the given style at the given canvas position. It doesn't need a
+// window or OpenGL context, and is used for headless exports.
+func GenerateCluster(seed int64, complexity *int, style gen.Style, canvasPos geom.Point) (*Cluster, error) {
+	comp, err := GenerateComposition(seed, complexity, style)
+	if err != nil {
+		return nil, err
+	}
+	return newCluster(comp, seed, complexity, canvasPos), nil
+}
+
+// ClusterFromLines creates a cluster traced around the given line set (see
+// gen.FromLines) at the given canvas position, colored after the seed.
+func ClusterFromLines(set gen.LineSet, seed int64, canvasPos geom.Point) (*Cluster, error) {
+	comp, err := gen.FromLines(set)
+	if err != nil {
+		return nil, err
+	}
+	return newCluster(comp, seed, nil, canvasPos), nil
+}
+
+// newCluster returns an unmanaged cluster with the given composition.
+func newCluster(comp gen.Composition, seed int64, complexity *int, canvasPos geom.Point) *Cluster {
+	return &Cluster{
+		ID:          -1,
+		UnitSize:    mesh.DefaultUnitSize,
+		CanvasPos:   canvasPos,
+		Composition: comp,
+		Seed:        seed,
+		Complexity:  complexity,
+		Scale:       1,
+		Dirty:       true,
+	}
+}
+
+// GenerateComposition generates a composition in the given style with the
+// given base seed, retrying with the seeds after it (up to
+// maxGenerationAttempts in all) while the lines drawn don't make one. Styles
+// that aren't possible fail right away, as do features that aren't, unless
+// they're drawn at random (without a complexity). It's safe for concurrent
+// use.
+func GenerateComposition(baseSeed int64, complexity *int, style gen.Style) (gen.Composition, error) {
+	if err := style.Validate(); err != nil {
+		return gen.Composition{}, err // no seed will do
+	}
 
-		if HasValidGeometry(comp) {
-			return comp, true
+	var err error
+	for attempt := 0; attempt < maxGenerationAttempts; attempt++ {
+		var comp gen.Composition
+		if comp, err = generateComposition(baseSeed+int64(attempt), complexity, style); err == nil {
+			return comp, nil
+		}
+		if errors.Is(err, gen.ErrInvalidFeatures) && complexity != nil {
+			return gen.Composition{}, err // picked by complexity, so the same for every seed
 		}
 
 		if attempt < maxGenerationAttempts-1 {
-			log.Printf("WARNING: Composition generation failed, retrying (attempt %d/%d)", attempt+1, maxGenerationAttempts)
+			log.Printf("WARNING: Composition generation failed (%v), retrying (attempt %d/%d)", err, attempt+1, maxGenerationAttempts)
 		}
 	}
+	return gen.Composition{}, fmt.Errorf("no valid composition after %d attempts: %w", maxGenerationAttempts, err)
+}
 
-	log.Printf("WARNING: Failed to generate valid composition after %d attempts", maxGenerationAttempts)
-	return gen.Composition{}, false
+// generateComposition generates a composition in the given style from the
+// given seed, without retrying.
+func generateComposition(seed int64, complexity *int, style gen.Style) (gen.Composition, error) {
+	features, err := gen.FeaturesFor(seed, complexity, style)
+	if err != nil {
+		return gen.Composition{}, err // e.g. a single line drawn, without a focus
+	}
+	return gen.Generate(seed, features)
 }
 
 // HasValidGeometry checks if a composition contains any valid geometry points.
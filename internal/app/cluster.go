@@ -1,23 +1,56 @@
 package app
 
 import (
-	"math"
+	"math/rand"
 	"sort"
 
 	"github.com/irfansharif/zellij/internal/gen"
 	"github.com/irfansharif/zellij/internal/geom"
 	"github.com/irfansharif/zellij/internal/memory"
+	"github.com/irfansharif/zellij/internal/mesh"
+	"github.com/irfansharif/zellij/internal/palette"
 )
 
 // Cluster represents a single cluster rendering with its position and metadata.
 type Cluster struct {
 	ID          memory.ClusterID // unique identifier
-	GridBounds  geom.Box         // bounding box in integer grid space
+	UnitSize    float64          // world (canvas) units per grid unit
 	CanvasPos   geom.Point       // position in canvas coordinates
 	Composition gen.Composition  // generated pattern
 	Seed        int64            // seed used for generation (for reproducibility)
 	Complexity  *int             // complexity level, nil for default randomization
+	Rotation    float64          // radians, about the cluster's center
+	Scale       float64          // uniform scale about the cluster's center
 	Dirty       bool             // marks cluster for GPU re-upload
+
+	// pending, if set, is the composition being generated for the cluster in
+	// the background (see App.Update), from its seed and complexity. Until
+	// it's done, the cluster keeps its previous composition, if any.
+	pending *pendingComposition
+}
+
+// pendingComposition is a composition being generated in the background. It's
+// shared by snapshots of the cluster (see History), so restoring one after
+// it's done doesn't generate it again.
+type pendingComposition struct {
+	style gen.Style
+
+	done bool // set on the main thread once generated, along with comp and err
+	comp gen.Composition
+	err  error // set if no composition could be generated
+}
+
+// Pending returns whether the cluster's composition is still being generated.
+func (c *Cluster) Pending() bool {
+	return c.pending != nil
+}
+
+// Style returns the style the cluster's composition is (being) generated in.
+func (c *Cluster) Style() gen.Style {
+	if c.pending != nil {
+		return c.pending.style
+	}
+	return c.Composition.Style
 }
 
 // SetComposition updates the cluster's composition and marks it dirty.
@@ -26,6 +59,26 @@ func (c *Cluster) SetComposition(comp gen.Composition) {
 	c.Dirty = true
 }
 
+// Palette returns the cluster's palette, derived deterministically from its
+// seed.
+func (c *Cluster) Palette() palette.Palette {
+	return palette.RandomPalette(rand.New(rand.NewSource(c.Seed)))
+}
+
+// Mesh returns the GL-independent description of the cluster, as used for
+// headless exports.
+func (c *Cluster) Mesh() mesh.Cluster {
+	return mesh.Cluster{
+		Composition: c.Composition,
+		UnitSize:    c.UnitSize,
+		CanvasPos:   c.CanvasPos,
+		Palette:     c.Palette(),
+		Seed:        c.Seed,
+		Rotation:    c.Rotation,
+		Scale:       c.Scale,
+	}
+}
+
 // SetSeed updates the cluster's seed.
 func (c *Cluster) SetSeed(seed int64) {
 	c.Seed = seed
@@ -41,6 +94,7 @@ type ClusterManager struct {
 	currentClusterID memory.ClusterID              // ID of the current cluster
 	currentSeed      int64                         // current seed
 	nextID           memory.ClusterID              // next cluster ID to assign
+	index            *spatialIndex                 // cluster canvas positions, for lookups
 }
 
 // NewClusterManager creates a new cluster manager.
@@ -49,34 +103,59 @@ func NewClusterManager(seed int64) *ClusterManager {
 		clusters:         make(map[memory.ClusterID]*Cluster),
 		currentClusterID: -1,
 		currentSeed:      seed,
+		index:            newSpatialIndex(),
 	}
 }
 
 // AddCluster adds a new cluster to the manager.
-func (cm *ClusterManager) AddCluster(gridBounds geom.Box, canvasPos geom.Point, comp gen.Composition, seed int64, complexity *int) *Cluster {
+func (cm *ClusterManager) AddCluster(canvasPos geom.Point, comp gen.Composition, seed int64, complexity *int) *Cluster {
 	cluster := &Cluster{
 		ID:          cm.nextID,
-		GridBounds:  gridBounds,
+		UnitSize:    mesh.DefaultUnitSize,
 		CanvasPos:   canvasPos,
 		Composition: comp,
 		Seed:        seed,
 		Complexity:  complexity,
+		Scale:       1,
 		Dirty:       true, // New clusters always need upload
 	}
-	cm.clusters[cluster.ID] = cluster
+	cm.setCluster(cluster)
 	cm.nextID++
 	return cluster
 }
 
+// setCluster adds the cluster to the manager, replacing any existing one with
+// the same ID.
+func (cm *ClusterManager) setCluster(cluster *Cluster) {
+	cm.clusters[cluster.ID] = cluster
+	cm.index.insert(cluster.ID, cluster.CanvasPos)
+}
+
 // RemoveCluster removes a cluster by ID.
 func (cm *ClusterManager) RemoveCluster(id memory.ClusterID) bool {
 	if _, ok := cm.clusters[id]; ok {
 		delete(cm.clusters, id)
+		cm.index.remove(id)
 		return true
 	}
 	return false
 }
 
+// TransformCluster sets a cluster's canvas position, rotation and scale,
+// marking it dirty.
+func (cm *ClusterManager) TransformCluster(id memory.ClusterID, canvasPos geom.Point, rotation, scale float64) bool {
+	cluster, ok := cm.clusters[id]
+	if !ok {
+		return false
+	}
+	cluster.CanvasPos = canvasPos
+	cluster.Rotation = rotation
+	cluster.Scale = scale
+	cluster.Dirty = true
+	cm.index.insert(id, canvasPos)
+	return true
+}
+
 // GetClusters returns all clusters sorted by ID (ascending).
 func (cm *ClusterManager) GetClusters() []*Cluster {
 	clusters := make([]*Cluster, 0, len(cm.clusters))
@@ -87,34 +166,25 @@ func (cm *ClusterManager) GetClusters() []*Cluster {
 	return clusters
 }
 
-// FindClosestClusters returns all clusters sorted by distance to the given point (closest first).
-// For clusters at equal distance, sorts by ID (highest first).
-func (cm *ClusterManager) FindClosestClusters(canvasX, canvasY float64) []*Cluster {
-	// Find all clusters and sort by distance to given coordinates
-	type sortKey struct {
-		distance float64
-		ID       memory.ClusterID
-	}
-
-	var sortKeys []sortKey
-	for _, cluster := range cm.clusters {
-		dx := cluster.CanvasPos.X - canvasX
-		dy := cluster.CanvasPos.Y - canvasY
-		distance := math.Sqrt(dx*dx + dy*dy)
-		sortKeys = append(sortKeys, sortKey{distance, cluster.ID})
+// FindClosestClusters returns up to k clusters sorted by distance to the given
+// point (closest first). For clusters at equal distance, sorts by ID (highest
+// first).
+func (cm *ClusterManager) FindClosestClusters(canvasX, canvasY float64, k int) []*Cluster {
+	ids := cm.index.nearest(canvasX, canvasY, k)
+	result := make([]*Cluster, len(ids))
+	for i, id := range ids {
+		result[i] = cm.clusters[id]
 	}
+	return result
+}
 
-	// Sort by distance (closest first), then by ID (highest first) for ties.
-	sort.Slice(sortKeys, func(i, j int) bool {
-		if math.Abs(sortKeys[i].distance-sortKeys[j].distance) < 1e-4 {
-			return sortKeys[i].ID > sortKeys[j].ID
-		}
-		return sortKeys[i].distance < sortKeys[j].distance
-	})
-
-	result := make([]*Cluster, len(sortKeys))
-	for i, sortKey := range sortKeys {
-		result[i] = cm.clusters[sortKey.ID]
+// FindClustersIn returns all clusters positioned within the given box (in
+// canvas coordinates), sorted by ID (ascending).
+func (cm *ClusterManager) FindClustersIn(box geom.Box) []*Cluster {
+	ids := cm.index.within(box)
+	result := make([]*Cluster, len(ids))
+	for i, id := range ids {
+		result[i] = cm.clusters[id]
 	}
 	return result
 }
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/irfansharif/zellij/internal/memory"
+)
+
+// maxHistory is the maximum number of changes kept around for undo.
+const maxHistory = 256
+
+// History records changes to the canvas' clusters (creations, deletions,
+// regenerations, complexity changes and moves/rotations/scales) so they can
+// be undone and redone.
+//
+// Changes are recorded as before/after snapshots of every cluster they
+// touched, with a nil snapshot standing in for a cluster that doesn't exist.
+// Compositions are never mutated in place, so snapshots are cheap: they share
+// tile geometry with the live clusters. Replaying a snapshot restores the
+// cluster under its original ID (IDs are never reused), and keeps GPU memory
+// consistent by releasing slots of removed clusters and marking restored ones
+// dirty for re-upload.
+//
+// Mutations are grouped into a single undoable change between
+// App.BeginChange and App.EndChange (e.g. for batch creations/deletions, or
+// all the moves making up a drag).
+// Mutations outside of one are recorded individually.
+type History struct {
+	undo, redo []change
+
+	depth   int     // nesting depth of open changes
+	pending *change // change being recorded, if depth > 0
+}
+
+// change is a single undoable unit, made up of edits to individual clusters in
+// the order they were first touched.
+type change struct {
+	edits []clusterEdit
+}
+
+// clusterEdit is the before/after state of a single cluster.
+type clusterEdit struct {
+	id            memory.ClusterID
+	before, after *Cluster // nil if the cluster didn't/doesn't exist
+}
+
+// CanUndo returns whether there's a change to undo. Nothing can be undone
+// while a change is being recorded.
+func (h *History) CanUndo() bool { return len(h.undo) > 0 && h.depth == 0 }
+
+// CanRedo returns whether there's a change to redo. Nothing can be redone
+// while a change is being recorded.
+func (h *History) CanRedo() bool { return len(h.redo) > 0 && h.depth == 0 }
+
+// Clear drops all recorded changes.
+func (h *History) Clear() {
+	h.undo, h.redo = nil, nil
+}
+
+// BeginChange opens a change, grouping all cluster mutations up until the
+// matching EndChange into a single undoable unit. Changes nest; only the
+// outermost one is recorded.
+func (app *App) BeginChange() {
+	h := app.History
+	h.depth++
+	if h.depth == 1 {
+		h.pending = &change{}
+	}
+}
+
+// EndChange closes a change opened with BeginChange, recording it if it's the
+// outermost one and anything was touched.
+func (app *App) EndChange() {
+	h := app.History
+	if h.depth == 0 {
+		return // defensive, unbalanced
+	}
+	h.depth--
+	if h.depth > 0 {
+		return
+	}
+
+	c := h.pending
+	h.pending = nil
+	for i := range c.edits {
+		c.edits[i].after = app.ClusterManager.snapshot(c.edits[i].id)
+	}
+	if len(c.edits) == 0 {
+		return // nothing changed
+	}
+
+	h.undo = append(h.undo, *c)
+	if len(h.undo) > maxHistory {
+		h.undo = h.undo[len(h.undo)-maxHistory:]
+	}
+	h.redo = nil
+}
+
+// Undo reverts the most recent change. Returns false if there's nothing to
+// undo. If reverting fails, the change is left in place to undo again (see
+// apply).
+func (app *App) Undo() (bool, error) {
+	h := app.History
+	if !h.CanUndo() {
+		return false, nil
+	}
+	c := h.undo[len(h.undo)-1]
+	if err := app.apply(c, true /* undo */); err != nil {
+		return false, err
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, c)
+	return true, nil
+}
+
+// Redo re-applies the most recently undone change. Returns false if there's
+// nothing to redo. If re-applying fails, the change is left in place to redo
+// again (see apply).
+func (app *App) Redo() (bool, error) {
+	h := app.History
+	if !h.CanRedo() {
+		return false, nil
+	}
+	c := h.redo[len(h.redo)-1]
+	if err := app.apply(c, false /* undo */); err != nil {
+		return false, err
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, c)
+	return true, nil
+}
+
+// apply restores the clusters the change touched to their state before it
+// (undo) or after it (redo), undoing in reverse order. If restoring a cluster
+// fails, the clusters restored so far are put back, so the canvas isn't left
+// half-reverted.
+func (app *App) apply(c change, undo bool) error {
+	edits := slices.Clone(c.edits)
+	if undo {
+		slices.Reverse(edits)
+	}
+	for i, edit := range edits {
+		if err := app.restore(edit.id, edit.state(!undo)); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				_ = app.restore(edits[j].id, edits[j].state(undo)) // best effort, report the first error
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// state returns the cluster's state after the edit, or before it.
+func (e clusterEdit) state(after bool) *Cluster {
+	if after {
+		return e.after
+	}
+	return e.before
+}
+
+// touch records the current state of the given cluster, ahead of it being
+// mutated, in the open change. Must be called between BeginChange and
+// EndChange.
+func (app *App) touch(id memory.ClusterID) {
+	c := app.History.pending
+	for _, edit := range c.edits {
+		if edit.id == id {
+			return // already recorded, keep the earliest state
+		}
+	}
+	c.edits = append(c.edits, clusterEdit{id: id, before: app.ClusterManager.snapshot(id)})
+}
+
+// restore sets the cluster with the given ID to the given snapshot, removing it
+// (and releasing its GPU slot) if the snapshot is nil.
+func (app *App) restore(id memory.ClusterID, state *Cluster) error {
+	cm := app.ClusterManager
+	if state == nil {
+		if app.MemoryController.HasCluster(id) {
+			if err := app.MemoryController.RemoveCluster(id); err != nil {
+				return fmt.Errorf("failed to remove cluster %d from GPU: %w", id, err)
+			}
+		}
+		cm.RemoveCluster(id)
+		return nil
+	}
+
+	cluster := *state
+	cluster.Dirty = true // (re-)upload
+	cm.setCluster(&cluster)
+	if p := cluster.pending; p != nil {
+		if p.done {
+			app.finish(&cluster)
+		} else {
+			app.submit(&cluster) // may have been superseded since
+		}
+	}
+	return nil
+}
+
+// snapshot returns a copy of the cluster with the given ID, or nil if there's
+// no such cluster.
+func (cm *ClusterManager) snapshot(id memory.ClusterID) *Cluster {
+	cluster, ok := cm.clusters[id]
+	if !ok {
+		return nil
+	}
+	c := *cluster
+	c.Dirty = false
+	return &c
+}
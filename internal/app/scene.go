@@ -0,0 +1,278 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/irfansharif/zellij/internal/gen"
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/memory"
+	"github.com/irfansharif/zellij/internal/mesh"
+)
+
+// sceneVersion is the version of the scene file format written by SaveScene.
+// LoadScene reads older versions too, but rejects files from newer ones, so a
+// reader never silently ignores fields that change what a cluster looks like.
+// Versions:
+//  1. Seeds, complexities, canvas positions, grid bounds and embedded
+//     geometry.
+//  2. Rotations and scales.
+//  3. Unit sizes, in place of grid bounds (which are ignored).
+//  4. Lattices.
+//  5. Symmetries and medallions.
+//  6. Periodic clusters.
+//  7. Masks.
+//  8. Features.
+const sceneVersion = 8
+
+// scene is the on-disk (JSON) representation of everything in the cluster
+// manager, plus the view. Since generation is deterministic from a seed (and
+// complexity), clusters are stored as just their parameters, and their
+// compositions regenerated on load. Geometry is embedded optionally, for
+// archival.
+type scene struct {
+	Version     int              `json:"version"`
+	View        sceneView        `json:"view"`
+	CurrentSeed int64            `json:"current_seed"`
+	NextID      memory.ClusterID `json:"next_id"`
+	Clusters    []sceneCluster   `json:"clusters"`
+}
+
+type sceneView struct {
+	Zoom float64 `json:"zoom"`
+	PanX float64 `json:"pan_x"`
+	PanY float64 `json:"pan_y"`
+}
+
+type sceneCluster struct {
+	ID         memory.ClusterID `json:"id"`
+	Seed       int64            `json:"seed"`
+	Complexity *int             `json:"complexity,omitempty"`
+	Lattice    string           `json:"lattice,omitempty"`
+	Symmetry   string           `json:"symmetry,omitempty"`
+	Medallion  bool             `json:"medallion,omitempty"`
+	Periodic   bool             `json:"periodic,omitempty"`
+	Mask       []float64        `json:"mask,omitempty"`      // x0,y0,x1,y1,...
+	Features   string           `json:"features,omitempty"`  // overrides, see gen.ParseOverrides
+	CanvasPos  [2]float64       `json:"canvas_pos"`          // x, y
+	UnitSize   float64          `json:"unit_size,omitempty"` // omitted (or 0) for the default
+	Rotation   float64          `json:"rotation,omitempty"`
+	Scale      float64          `json:"scale,omitempty"` // omitted (or 0) for 1
+	Geometry   *sceneGeometry   `json:"geometry,omitempty"`
+}
+
+// sceneGeometry embeds a cluster's full composition. Points are stored flat,
+// [x0,y0,x1,y1,...], like the filler library.
+type sceneGeometry struct {
+	Tiles    []sceneTile `json:"tiles"`
+	Boundary []float64   `json:"boundary"`
+	GridSide int         `json:"grid_side"`
+	Shimmer  int         `json:"shimmer"`
+	Period   []float64   `json:"period,omitempty"` // periodic clusters only
+}
+
+type sceneTile struct {
+	Vertex [2]float64 `json:"vertex"`
+	Path   []float64  `json:"path"`
+}
+
+// SaveScene writes the current clusters and view to w. If embedGeometry is
+// set, full tile geometry is included too, and used as-is on load instead of
+// regenerating from seeds.
+func (app *App) SaveScene(w io.Writer, embedGeometry bool) error {
+	cm := app.ClusterManager
+	s := scene{
+		Version: sceneVersion,
+		View: sceneView{
+			Zoom: app.View.Zoom,
+			PanX: app.View.PanX,
+			PanY: app.View.PanY,
+		},
+		CurrentSeed: cm.currentSeed,
+		NextID:      cm.nextID,
+		Clusters:    make([]sceneCluster, 0, len(cm.clusters)),
+	}
+	for _, cluster := range cm.GetClusters() {
+		style := cluster.Style()
+		sc := sceneCluster{
+			ID:         cluster.ID,
+			Seed:       cluster.Seed,
+			Complexity: cluster.Complexity,
+			Lattice:    style.Lattice,
+			Symmetry:   style.Symmetry,
+			Medallion:  style.Medallion,
+			Periodic:   style.Periodic,
+			Features:   style.Overrides.String(),
+			CanvasPos:  [2]float64{cluster.CanvasPos.X, cluster.CanvasPos.Y},
+			Rotation:   cluster.Rotation,
+		}
+		if cluster.UnitSize != mesh.DefaultUnitSize {
+			sc.UnitSize = cluster.UnitSize
+		}
+		if cluster.Scale != 1 {
+			sc.Scale = cluster.Scale
+		}
+		if len(style.Mask) > 0 {
+			sc.Mask = flattenPoints(style.Mask)
+		}
+		if embedGeometry && !cluster.Pending() { // pending ones are regenerated on load
+			sc.Geometry = encodeGeometry(cluster.Composition)
+		}
+		s.Clusters = append(s.Clusters, sc)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// LoadScene replaces all current clusters and the view with the scene read
+// from r. Clusters without embedded geometry are regenerated from their seeds,
+// in the background, as CreateCluster's are. Undo history is cleared. The
+// caller is expected to prepare the renderer afterwards.
+func (app *App) LoadScene(r io.Reader) error {
+	var s scene
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("decoding scene: %w", err)
+	}
+	if s.Version < 1 || s.Version > sceneVersion {
+		return fmt.Errorf("unsupported scene version %d (want <= %d)", s.Version, sceneVersion)
+	}
+
+	// Build everything before touching current state, so a bad file leaves
+	// the canvas untouched.
+	clusters := make(map[memory.ClusterID]*Cluster, len(s.Clusters))
+	nextID := s.NextID
+	for _, sc := range s.Clusters {
+		if _, ok := clusters[sc.ID]; ok {
+			return fmt.Errorf("duplicate cluster ID %d", sc.ID)
+		}
+
+		style := gen.Style{Lattice: sc.Lattice, Symmetry: sc.Symmetry, Medallion: sc.Medallion, Periodic: sc.Periodic}
+		if len(sc.Mask) > 0 {
+			style.Mask = unflattenPoints(sc.Mask)
+		}
+		var err error
+		if style.Overrides, err = gen.ParseOverrides(sc.Features); err != nil {
+			return fmt.Errorf("cluster %d: %w", sc.ID, err)
+		}
+		if err := style.Validate(); err != nil {
+			return fmt.Errorf("cluster %d: %w", sc.ID, err)
+		}
+		comp := gen.Composition{Style: style}
+		var pending *pendingComposition // regenerated once the load's done
+		if sc.Geometry != nil {
+			comp = decodeGeometry(sc.Geometry)
+			comp.Style = style
+		} else {
+			pending = &pendingComposition{style: style}
+		}
+
+		scale := sc.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		unitSize := sc.UnitSize
+		if unitSize == 0 {
+			unitSize = mesh.DefaultUnitSize
+		}
+		clusters[sc.ID] = &Cluster{
+			ID:          sc.ID,
+			UnitSize:    unitSize,
+			CanvasPos:   geom.MakePoint(sc.CanvasPos[0], sc.CanvasPos[1]),
+			Composition: comp,
+			Seed:        sc.Seed,
+			Complexity:  sc.Complexity,
+			Rotation:    sc.Rotation,
+			Scale:       scale,
+			Dirty:       true,
+			pending:     pending,
+		}
+		if sc.ID >= nextID {
+			nextID = sc.ID + 1 // defensive, never reuse IDs
+		}
+	}
+
+	// Release GPU memory held by current clusters.
+	cm := app.ClusterManager
+	for id := range cm.clusters {
+		if !app.MemoryController.HasCluster(id) {
+			continue // never uploaded
+		}
+		if err := app.MemoryController.RemoveCluster(id); err != nil {
+			return fmt.Errorf("failed to remove cluster %d from GPU: %w", id, err)
+		}
+	}
+
+	cm.clusters = make(map[memory.ClusterID]*Cluster, len(clusters))
+	cm.index = newSpatialIndex()
+	for _, cluster := range clusters {
+		cm.setCluster(cluster)
+		if cluster.Pending() {
+			app.submit(cluster)
+		}
+	}
+	cm.currentClusterID = -1
+	cm.currentSeed = s.CurrentSeed
+	cm.nextID = nextID
+	app.History.Clear() // nothing to undo into
+
+	app.View.SetZoom(s.View.Zoom)
+	app.View.SetPan(s.View.PanX, s.View.PanY)
+	return nil
+}
+
+// encodeGeometry converts a composition into its scene representation.
+func encodeGeometry(comp gen.Composition) *sceneGeometry {
+	g := &sceneGeometry{
+		Tiles:    make([]sceneTile, len(comp.Tiles)),
+		Boundary: flattenPoints(comp.Boundary),
+		GridSide: comp.GridSide,
+		Shimmer:  comp.Shimmer,
+	}
+	if comp.Periodic {
+		g.Period = flattenPoints(comp.Period[:])
+	}
+	for i, tile := range comp.Tiles {
+		g.Tiles[i] = sceneTile{
+			Vertex: [2]float64{tile.Vertex.X, tile.Vertex.Y},
+			Path:   flattenPoints(tile.Path),
+		}
+	}
+	return g
+}
+
+// decodeGeometry converts a scene representation back into a composition.
+func decodeGeometry(g *sceneGeometry) gen.Composition {
+	comp := gen.Composition{
+		Tiles:    make([]gen.Tile, len(g.Tiles)),
+		Boundary: unflattenPoints(g.Boundary),
+		GridSide: g.GridSide,
+		Shimmer:  g.Shimmer,
+	}
+	copy(comp.Period[:], unflattenPoints(g.Period))
+	for i, tile := range g.Tiles {
+		comp.Tiles[i] = gen.Tile{
+			Vertex: geom.MakePoint(tile.Vertex[0], tile.Vertex[1]),
+			Path:   unflattenPoints(tile.Path),
+		}
+	}
+	return comp
+}
+
+func flattenPoints(points []geom.Point) []float64 {
+	flat := make([]float64, 0, 2*len(points))
+	for _, p := range points {
+		flat = append(flat, p.X, p.Y)
+	}
+	return flat
+}
+
+func unflattenPoints(flat []float64) []geom.Point {
+	points := make([]geom.Point, len(flat)/2)
+	for i := range points {
+		points[i] = geom.MakePoint(flat[2*i], flat[2*i+1])
+	}
+	return points
+}
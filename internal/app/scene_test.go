@@ -0,0 +1,167 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/irfansharif/zellij/internal/gen"
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/memory"
+	"github.com/irfansharif/zellij/internal/worker"
+)
+
+// newTestApp returns an app with no window or renderer.
+func newTestApp(seed int64) *App {
+	return &App{
+		View:             NewView(800, 600),
+		ClusterManager:   NewClusterManager(seed),
+		MemoryController: memory.NewMemoryController(),
+		History:          &History{},
+		generations:      worker.NewPool[memory.ClusterID, generation](1),
+	}
+}
+
+// addTestCluster adds a cluster generated from the given seed and complexity,
+// in the given style.
+func addTestCluster(t *testing.T, app *App, seed int64, complexity *int, style gen.Style, pos geom.Point) *Cluster {
+	t.Helper()
+	comp, err := GenerateComposition(seed, complexity, style)
+	if err != nil {
+		t.Fatalf("seed %d: %v", seed, err)
+	}
+	return app.ClusterManager.AddCluster(pos, comp, seed, complexity)
+}
+
+// finishGenerating waits for the app's clusters' compositions being generated
+// in the background, and applies them, as Update does once they're done.
+func finishGenerating(t *testing.T, app *App) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Minute); ; time.Sleep(time.Millisecond) {
+		app.finishGenerated()
+		if !slices.ContainsFunc(app.ClusterManager.GetClusters(), (*Cluster).Pending) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("compositions still being generated")
+		}
+	}
+}
+
+// sceneClusters returns the app's clusters, normalized for comparison:
+// ignoring whether they're yet to be uploaded, and empty boundaries (periodic
+// clusters have none) however they're represented.
+func sceneClusters(app *App) []Cluster {
+	var clusters []Cluster
+	for _, c := range app.ClusterManager.GetClusters() {
+		cluster := *c
+		cluster.Dirty = false
+		if len(cluster.Composition.Boundary) == 0 {
+			cluster.Composition.Boundary = nil
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+func TestSceneRoundTrip(t *testing.T) {
+	app := newTestApp(100)
+	complexity := 3
+	overrides, err := gen.ParseOverrides("density=8,lines=12,focus=sixteen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	circle, _ := gen.BuiltinMask("circle")
+	addTestCluster(t, app, 1, nil, gen.Style{}, geom.MakePoint(0, 0))
+	addTestCluster(t, app, 2, &complexity, gen.Style{Lattice: "Hex", Symmetry: "D6", Medallion: true}, geom.MakePoint(-700, 300))
+	addTestCluster(t, app, 3, nil, gen.Style{Periodic: true}, geom.MakePoint(900, -1200))
+	addTestCluster(t, app, 4, nil, gen.Style{}, geom.MakePoint(1e4, 1e4))
+	addTestCluster(t, app, 5, nil, gen.Style{Mask: circle}, geom.MakePoint(50, 50))
+	addTestCluster(t, app, 6, nil, gen.Style{Overrides: overrides}, geom.MakePoint(-300, -300))
+	app.ClusterManager.clusters[1].UnitSize = 7
+	app.ClusterManager.TransformCluster(0, geom.MakePoint(10, 20), math.Pi/3, 1.5)
+	app.ClusterManager.RemoveCluster(3) // IDs aren't reused after a load
+	app.ClusterManager.IncrementSeed()
+	app.View.SetZoom(2.5)
+	app.View.SetPan(-40, 60)
+
+	for _, embed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("embed=%t", embed), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := app.SaveScene(&buf, embed); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(buf.String(), `"geometry"`); got != embed {
+				t.Errorf("geometry embedded = %t, want %t", got, embed)
+			}
+
+			loaded := newTestApp(0)
+			addTestCluster(t, loaded, 42, nil, gen.Style{}, geom.MakePoint(0, 0)) // replaced
+			if err := loaded.LoadScene(&buf); err != nil {
+				t.Fatal(err)
+			}
+			for _, cluster := range loaded.ClusterManager.GetClusters() {
+				if cluster.Pending() == embed {
+					t.Errorf("cluster %d pending = %t, want %t", cluster.ID, cluster.Pending(), !embed)
+				}
+			}
+			finishGenerating(t, loaded)
+			if got, want := sceneClusters(loaded), sceneClusters(app); !reflect.DeepEqual(got, want) {
+				t.Errorf("loaded clusters differ:\n got %+v\nwant %+v", got, want)
+			}
+			if got, want := *loaded.View, *app.View; got.Zoom != want.Zoom || got.PanX != want.PanX || got.PanY != want.PanY {
+				t.Errorf("loaded view %+v, want %+v", got, want)
+			}
+			cm, want := loaded.ClusterManager, app.ClusterManager
+			if cm.currentSeed != want.currentSeed || cm.nextID != want.nextID {
+				t.Errorf("loaded seed %d, next ID %d, want %d, %d", cm.currentSeed, cm.nextID, want.currentSeed, want.nextID)
+			}
+		})
+	}
+}
+
+func TestSceneVersions(t *testing.T) {
+	// Written before clusters could be transformed, or sized in world units:
+	// loads untransformed, at the default size, ignoring its grid bounds.
+	v1 := `{"version": 1, "view": {"zoom": 1}, "current_seed": 8, "next_id": 2,
+		"clusters": [{"id": 1, "seed": 7, "canvas_pos": [10, 20], "grid_bounds": [0, 0, 25, 25]}]}`
+	app := newTestApp(0)
+	if err := app.LoadScene(strings.NewReader(v1)); err != nil {
+		t.Fatalf("loading a version 1 scene: %v", err)
+	}
+	finishGenerating(t, app)
+	want := newTestApp(0)
+	want.ClusterManager.nextID = 1
+	addTestCluster(t, want, 7, nil, gen.Style{}, geom.MakePoint(10, 20))
+	if got, want := sceneClusters(app), sceneClusters(want); !reflect.DeepEqual(got, want) {
+		t.Errorf("version 1 scene loaded as:\n got %+v\nwant %+v", got, want)
+	}
+
+	for _, tc := range []struct {
+		name, scene, err string
+	}{
+		{name: "newer", scene: fmt.Sprintf(`{"version": %d}`, sceneVersion+1), err: "unsupported scene version"},
+		{name: "unversioned", scene: `{"clusters": []}`, err: "unsupported scene version 0"},
+		{name: "malformed", scene: `{"version": `, err: "decoding scene"},
+		{name: "duplicate", scene: `{"version": 1, "clusters": [{"id": 1, "seed": 1}, {"id": 1, "seed": 2}]}`, err: "duplicate cluster ID 1"},
+		{name: "impossible", scene: `{"version": 5, "clusters": [{"id": 1, "seed": 1, "symmetry": "D6"}]}`, err: "cluster 1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newTestApp(0)
+			addTestCluster(t, app, 42, nil, gen.Style{}, geom.MakePoint(0, 0))
+			before := sceneClusters(app)
+			err := app.LoadScene(strings.NewReader(tc.scene))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("LoadScene() = %v, want an error containing %q", err, tc.err)
+			}
+			if after := sceneClusters(app); !reflect.DeepEqual(after, before) {
+				t.Errorf("failed load changed the clusters")
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"math"
+	"sort"
+
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/memory"
+)
+
+// spatialCellSize is the side length of spatial index cells in canvas
+// coordinates, roughly the size of a single cluster on screen.
+const spatialCellSize = 512.0
+
+// tieEpsilon is the distance below which clusters are considered equidistant
+// (and ordered by ID instead).
+const tieEpsilon = 1e-4
+
+// cellKey identifies a single cell in the spatial index.
+type cellKey struct{ x, y int }
+
+// spatialIndex is a uniform grid over cluster canvas positions, answering
+// k-nearest and rectangle queries without looking at every cluster.
+type spatialIndex struct {
+	cells     map[cellKey][]memory.ClusterID
+	positions map[memory.ClusterID]geom.Point
+}
+
+func newSpatialIndex() *spatialIndex {
+	return &spatialIndex{
+		cells:     make(map[cellKey][]memory.ClusterID),
+		positions: make(map[memory.ClusterID]geom.Point),
+	}
+}
+
+func cellFor(p geom.Point) cellKey {
+	return cellKey{
+		x: int(math.Floor(p.X / spatialCellSize)),
+		y: int(math.Floor(p.Y / spatialCellSize)),
+	}
+}
+
+// insert adds the cluster at the given position, replacing any previous entry
+// for it.
+func (si *spatialIndex) insert(id memory.ClusterID, pos geom.Point) {
+	si.remove(id)
+	key := cellFor(pos)
+	si.cells[key] = append(si.cells[key], id)
+	si.positions[id] = pos
+}
+
+// remove drops the cluster, if present.
+func (si *spatialIndex) remove(id memory.ClusterID) {
+	pos, ok := si.positions[id]
+	if !ok {
+		return
+	}
+	delete(si.positions, id)
+
+	key := cellFor(pos)
+	ids := si.cells[key]
+	for i, other := range ids {
+		if other == id {
+			ids[i] = ids[len(ids)-1]
+			ids = ids[:len(ids)-1]
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(si.cells, key)
+	} else {
+		si.cells[key] = ids
+	}
+}
+
+// nearest returns up to k cluster IDs sorted by distance to (x, y), closest
+// first, with equidistant clusters ordered by ID (highest first).
+//
+// It searches rings of cells of increasing (Chebyshev) radius around the
+// query's cell. After searching radius r, every unseen cluster is at least
+// r*spatialCellSize away, so we stop once k found clusters are closer than
+// that.
+func (si *spatialIndex) nearest(x, y float64, k int) []memory.ClusterID {
+	if k <= 0 || len(si.positions) == 0 {
+		return nil
+	}
+	if k > len(si.positions) {
+		k = len(si.positions)
+	}
+
+	query := geom.MakePoint(x, y)
+	var found []candidate
+	add := func(ids []memory.ClusterID) {
+		for _, id := range ids {
+			found = append(found, candidate{id: id, distance: distance(si.positions[id], query)})
+		}
+	}
+
+	center := cellFor(query)
+	for r, seen, scanned := 0, 0, 0; seen < len(si.positions); r++ {
+		scanned += max(1, 8*r) // cells in ring r
+		if scanned > len(si.cells) {
+			// Clusters are sparse relative to the search radius, it's cheaper
+			// to just look at all of them.
+			found = found[:0]
+			for _, ids := range si.cells {
+				add(ids)
+			}
+			break
+		}
+
+		si.ring(center, r, func(ids []memory.ClusterID) {
+			add(ids)
+			seen += len(ids)
+		})
+
+		if len(found) < k {
+			continue
+		}
+		sortCandidates(found)
+		if found[k-1].distance+tieEpsilon < float64(r)*spatialCellSize {
+			break // nothing unseen can be closer (or tie)
+		}
+	}
+
+	sortCandidates(found)
+	ids := make([]memory.ClusterID, k)
+	for i := range ids {
+		ids[i] = found[i].id
+	}
+	return ids
+}
+
+// within returns the IDs of all clusters positioned inside the given box,
+// sorted by ID (ascending).
+func (si *spatialIndex) within(box geom.Box) []memory.ClusterID {
+	lo := cellFor(geom.MakePoint(box.X, box.Y))
+	hi := cellFor(geom.MakePoint(box.X+box.W, box.Y+box.H))
+
+	var ids []memory.ClusterID
+	if (hi.x-lo.x+1)*(hi.y-lo.y+1) > len(si.cells) {
+		// Large query relative to the populated cells, walk those instead.
+		for _, cellIDs := range si.cells {
+			ids = si.appendWithin(ids, cellIDs, box)
+		}
+	} else {
+		for cx := lo.x; cx <= hi.x; cx++ {
+			for cy := lo.y; cy <= hi.y; cy++ {
+				ids = si.appendWithin(ids, si.cells[cellKey{cx, cy}], box)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (si *spatialIndex) appendWithin(dst, ids []memory.ClusterID, box geom.Box) []memory.ClusterID {
+	for _, id := range ids {
+		p := si.positions[id]
+		if p.X >= box.X && p.X <= box.X+box.W && p.Y >= box.Y && p.Y <= box.Y+box.H {
+			dst = append(dst, id)
+		}
+	}
+	return dst
+}
+
+// ring calls fn with the IDs in every non-empty cell at exactly Chebyshev
+// distance r from center.
+func (si *spatialIndex) ring(center cellKey, r int, fn func([]memory.ClusterID)) {
+	visit := func(cx, cy int) {
+		if ids := si.cells[cellKey{cx, cy}]; len(ids) > 0 {
+			fn(ids)
+		}
+	}
+	if r == 0 {
+		visit(center.x, center.y)
+		return
+	}
+	for d := -r; d <= r; d++ {
+		visit(center.x+d, center.y-r) // top row
+		visit(center.x+d, center.y+r) // bottom row
+	}
+	for d := -r + 1; d <= r-1; d++ {
+		visit(center.x-r, center.y+d) // left column
+		visit(center.x+r, center.y+d) // right column
+	}
+}
+
+func distance(p, q geom.Point) float64 {
+	dx, dy := p.X-q.X, p.Y-q.Y
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// candidate is a cluster considered in a nearest-neighbour search.
+type candidate struct {
+	id       memory.ClusterID
+	distance float64
+}
+
+// sortCandidates sorts by distance (closest first), then by ID (highest first)
+// for ties.
+func sortCandidates(cs []candidate) {
+	sort.Slice(cs, func(i, j int) bool {
+		if math.Abs(cs[i].distance-cs[j].distance) < tieEpsilon {
+			return cs[i].id > cs[j].id
+		}
+		return cs[i].distance < cs[j].distance
+	})
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/memory"
+)
+
+// checkNearest checks, against every cluster, that got is what nearest
+// should return for k clusters around query: the k closest, sorted by
+// distance and then by ID (highest first) for ties, as in sortCandidates.
+func checkNearest(t *testing.T, positions map[memory.ClusterID]geom.Point, query geom.Point, k int, got []memory.ClusterID) {
+	t.Helper()
+	before := func(a, b memory.ClusterID) bool {
+		da, db := distance(positions[a], query), distance(positions[b], query)
+		if math.Abs(da-db) < tieEpsilon {
+			return a > b
+		}
+		return da < db
+	}
+
+	if want := min(k, len(positions)); len(got) != want {
+		t.Fatalf("nearest(%v, %v, %d) = %v, want %d clusters", query.X, query.Y, k, got, want)
+	}
+	in := make(map[memory.ClusterID]bool)
+	for i, id := range got {
+		if _, ok := positions[id]; !ok || in[id] {
+			t.Fatalf("nearest(%v, %v, %d) = %v, with unknown or repeated cluster %d", query.X, query.Y, k, got, id)
+		}
+		in[id] = true
+		if i > 0 && before(id, got[i-1]) {
+			t.Fatalf("nearest(%v, %v, %d) = %v, with %d out of order", query.X, query.Y, k, got, id)
+		}
+	}
+	for id := range positions {
+		if !in[id] && len(got) > 0 && before(id, got[len(got)-1]) {
+			t.Fatalf("nearest(%v, %v, %d) = %v, missing %d", query.X, query.Y, k, got, id)
+		}
+	}
+}
+
+// bruteWithin returns the given clusters inside box, sorted by ID.
+func bruteWithin(positions map[memory.ClusterID]geom.Point, box geom.Box) []memory.ClusterID {
+	var ids []memory.ClusterID
+	for id, p := range positions {
+		if p.X >= box.X && p.X <= box.X+box.W && p.Y >= box.Y && p.Y <= box.Y+box.H {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSpatialIndexNearest(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		n      int
+		spread float64 // clusters are within ±spread of the origin
+		grid   bool    // on a coarse grid, so many are equidistant
+	}{
+		{name: "dense", n: 500, spread: 2000},
+		{name: "sparse", n: 50, spread: 1e6},
+		{name: "ties", n: 500, spread: 1500, grid: true},
+		{name: "single", n: 1, spread: 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			coord := func() float64 {
+				v := (2*rng.Float64() - 1) * tc.spread
+				if tc.grid {
+					// Multiples of 100, so distances are either equal or
+					// far further apart than tieEpsilon.
+					v = float64(int(v) / 100 * 100)
+				}
+				return v
+			}
+
+			si := newSpatialIndex()
+			positions := make(map[memory.ClusterID]geom.Point)
+			for i := 0; i < tc.n; i++ {
+				id, p := memory.ClusterID(i), geom.MakePoint(coord(), coord())
+				si.insert(id, p)
+				positions[id] = p
+			}
+			// Move and remove some, to exercise both.
+			for i := 0; i < tc.n/10; i++ {
+				id := memory.ClusterID(rng.Intn(tc.n))
+				if rng.Intn(2) == 0 {
+					si.remove(id)
+					delete(positions, id)
+				} else {
+					p := geom.MakePoint(coord(), coord())
+					si.insert(id, p)
+					positions[id] = p
+				}
+			}
+
+			for i := 0; i < 200; i++ {
+				query := geom.MakePoint(coord(), coord())
+				for _, k := range []int{1, 3, 10, len(positions) + 1} {
+					checkNearest(t, positions, query, k, si.nearest(query.X, query.Y, k))
+				}
+			}
+		})
+	}
+}
+
+func TestSpatialIndexNearestTies(t *testing.T) {
+	si := newSpatialIndex()
+	// Four clusters equidistant from the origin, straddling cells on either
+	// side of it, and a fifth further out in a negative cell.
+	si.insert(3, geom.MakePoint(100, 0))
+	si.insert(7, geom.MakePoint(-100, 0))
+	si.insert(5, geom.MakePoint(0, 100))
+	si.insert(1, geom.MakePoint(0, -100))
+	si.insert(9, geom.MakePoint(-600, -600))
+
+	for _, tc := range []struct {
+		k    int
+		want []memory.ClusterID
+	}{
+		{k: 0, want: nil},
+		{k: 1, want: []memory.ClusterID{7}},
+		{k: 2, want: []memory.ClusterID{7, 5}},
+		{k: 4, want: []memory.ClusterID{7, 5, 3, 1}},
+		{k: 10, want: []memory.ClusterID{7, 5, 3, 1, 9}},
+	} {
+		if got := si.nearest(0, 0, tc.k); !slices.Equal(got, tc.want) {
+			t.Errorf("nearest(0, 0, %d) = %v, want %v", tc.k, got, tc.want)
+		}
+	}
+
+	if got, want := si.nearest(-1000, -1000, 1), []memory.ClusterID{9}; !slices.Equal(got, want) {
+		t.Errorf("nearest(-1000, -1000, 1) = %v, want %v", got, want)
+	}
+	if got := newSpatialIndex().nearest(0, 0, 3); got != nil {
+		t.Errorf("nearest on an empty index = %v, want nil", got)
+	}
+}
+
+func TestSpatialIndexWithin(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	si := newSpatialIndex()
+	positions := make(map[memory.ClusterID]geom.Point)
+	for i := 0; i < 300; i++ {
+		id, p := memory.ClusterID(i), geom.MakePoint((2*rng.Float64()-1)*3000, (2*rng.Float64()-1)*3000)
+		si.insert(id, p)
+		positions[id] = p
+	}
+
+	for i := 0; i < 200; i++ {
+		box := geom.Box{
+			X: (2*rng.Float64() - 1) * 3000, Y: (2*rng.Float64() - 1) * 3000,
+			W: rng.Float64() * 2000, H: rng.Float64() * 2000,
+		}
+		if i%10 == 0 {
+			box.W, box.H = 1e5, 1e5 // larger than the populated cells
+		}
+		if got, want := si.within(box), bruteWithin(positions, box); !slices.Equal(got, want) {
+			t.Fatalf("within(%+v) = %v, want %v", box, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package export
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/mesh"
+	"github.com/irfansharif/zellij/internal/raster"
+)
+
+// pngMargin is the padding (as a fraction of the image's smaller side) left
+// around content when fitting it to the image.
+const pngMargin = 0.05
+
+// PNGOptions configures raster output.
+type PNGOptions struct {
+	Width, Height int // output resolution in pixels
+
+	// Fit frames all content within the image, ignoring Zoom and Pan.
+	// Otherwise the view is computed like the renderer's, treating the image
+	// as the viewport.
+	Fit              bool
+	Zoom, PanX, PanY float64
+
+	Samples    int        // per-axis supersampling factor, zero for the default
+	Background color.RGBA // zero value is transparent
+}
+
+// Image rasterizes the given clusters, each at its canvas position, into a
+// new image. Periodic clusters are repeated to fill the image (underneath
+// everything else), though only their translation unit is framed when
+// fitting.
+func Image(clusters []mesh.Cluster, opts PNGOptions) (*image.RGBA, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, fmt.Errorf("invalid image dimensions %dx%d", opts.Width, opts.Height)
+	}
+	groups := make([][]mesh.Polygon, 0, len(clusters))
+	var polys []mesh.Polygon
+	for i, c := range clusters {
+		cpolys, _, err := mesh.Polygons(c)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %d (seed=%d): %w", i, c.Seed, err)
+		}
+		groups = append(groups, cpolys)
+		polys = append(polys, cpolys...)
+	}
+
+	zoom, panX, panY := opts.Zoom, opts.PanX, opts.PanY
+	if opts.Fit {
+		bounds, ok := mesh.Bounds(polys)
+		if !ok {
+			return nil, fmt.Errorf("nothing to export")
+		}
+		// Scale content to fit within the margins, and pan its center onto the
+		// image's center.
+		avail := 1 - 2*pngMargin
+		zoom = min(avail*float64(opts.Width)/bounds.W, avail*float64(opts.Height)/bounds.H)
+		cx, cy := float64(opts.Width)/2, float64(opts.Height)/2
+		panX = -zoom * (bounds.X + bounds.W/2 - cx)
+		panY = -zoom * (bounds.Y + bounds.H/2 - cy)
+	}
+	if zoom == 0 {
+		zoom = 1
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+
+	transform := mesh.ViewTransform(opts.Width, opts.Height, zoom, panX, panY)
+	toWorld, err := transform.Inv()
+	if err != nil {
+		return nil, err
+	}
+	order, err := repeat(clusters, groups, toWorld.MulBox(geom.MakeBox(0, 0, float64(opts.Width), float64(opts.Height))))
+	if err != nil {
+		return nil, err
+	}
+	polys = polys[:0]
+	for _, i := range order {
+		polys = append(polys, groups[i]...)
+	}
+	if err := raster.Rasterize(img, mesh.Vertices(polys), transform, opts.Samples); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// PNG rasterizes the given clusters (see Image) and encodes the result as PNG.
+func PNG(w io.Writer, clusters []mesh.Cluster, opts PNGOptions) error {
+	img, err := Image(clusters, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// repeat fills the given region of world space with periodic clusters'
+// translation units, replacing their polygons (copy (0, 0) of the unit, see
+// mesh.Polygons) in groups. It returns the order to draw clusters in: periodic
+// ones first, underneath everything else.
+func repeat(clusters []mesh.Cluster, groups [][]mesh.Polygon, region geom.Box) ([]int, error) {
+	var periodic, rest []int
+	for i, c := range clusters {
+		if !c.Composition.Periodic {
+			rest = append(rest, i)
+			continue
+		}
+		polys, _, err := mesh.PolygonsIn(c, region)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %d (seed=%d): %w", i, c.Seed, err)
+		}
+		groups[i] = polys
+		periodic = append(periodic, i)
+	}
+	return append(periodic, rest...), nil
+}
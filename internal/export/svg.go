@@ -0,0 +1,97 @@
+// Package export writes clusters out of the application and into files other
+// tools understand. It builds on the mesh package and never touches OpenGL, so
+// it works headless (e.g. on build servers).
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/mesh"
+)
+
+// svgMargin is the padding (as a fraction of the content's larger side) left
+// around exported content.
+const svgMargin = 0.02
+
+// SVG writes the given clusters, each at its canvas position, as a standalone
+// SVG document. Every filler shape becomes a filled <path> in its palette
+// colour, grouped per cluster. The document's viewBox is fit to the content,
+// with one user unit per world unit. Periodic clusters are repeated to fill
+// the viewBox (underneath everything else), though only their translation
+// unit is fit.
+func SVG(w io.Writer, clusters []mesh.Cluster) error {
+	groups := make([][]mesh.Polygon, 0, len(clusters))
+	var all []mesh.Polygon
+	for i, c := range clusters {
+		polys, _, err := mesh.Polygons(c)
+		if err != nil {
+			return fmt.Errorf("cluster %d (seed=%d): %w", i, c.Seed, err)
+		}
+		groups = append(groups, polys)
+		all = append(all, polys...)
+	}
+
+	bounds, ok := mesh.Bounds(all)
+	if !ok {
+		return fmt.Errorf("nothing to export")
+	}
+	pad := svgMargin * max(bounds.W, bounds.H)
+	bounds = geom.MakeBox(bounds.X-pad, bounds.Y-pad, bounds.W+2*pad, bounds.H+2*pad)
+	order, err := repeat(clusters, groups, bounds)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="%s %s %s %s">`+"\n",
+		fmtFloat(bounds.W), fmtFloat(bounds.H),
+		fmtFloat(bounds.X), fmtFloat(bounds.Y), fmtFloat(bounds.W), fmtFloat(bounds.H))
+	for _, i := range order {
+		fmt.Fprintf(bw, `<g id="cluster-%d" data-seed="%d">`+"\n", i, clusters[i].Seed)
+		for _, poly := range groups[i] {
+			writeSVGPath(bw, poly)
+		}
+		fmt.Fprintf(bw, "</g>\n")
+	}
+	fmt.Fprintf(bw, "</svg>\n")
+	return bw.Flush()
+}
+
+// writeSVGPath writes a single polygon as a closed, filled <path>.
+func writeSVGPath(w *bufio.Writer, poly mesh.Polygon) {
+	if len(poly.Path) < 3 {
+		return
+	}
+	w.WriteString(`<path d="`)
+	for i, p := range poly.Path {
+		if i == 0 {
+			w.WriteString("M")
+		} else {
+			w.WriteString(" L")
+		}
+		w.WriteString(fmtFloat(p.X))
+		w.WriteString(" ")
+		w.WriteString(fmtFloat(p.Y))
+	}
+	fmt.Fprintf(w, ` Z" fill="%s"`, hexColour(poly.Colour))
+	if poly.Colour.A != 255 {
+		fmt.Fprintf(w, ` fill-opacity="%s"`, fmtFloat(float64(poly.Colour.A)/255.0))
+	}
+	w.WriteString("/>\n")
+}
+
+// hexColour formats a colour as #rrggbb.
+func hexColour(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// fmtFloat formats coordinates compactly, with enough precision for print.
+func fmtFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 32)
+}
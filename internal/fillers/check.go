@@ -0,0 +1,76 @@
+package fillers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// CheckLibrary reads a filler library, as LoadLibrary does, and returns
+// what's wrong with its patterns: those that can't fit a tile (with fewer
+// than three bounds, a degenerate reference segment, or bounds whose
+// signature isn't the one they're listed under), and what LoadLibrary and
+// renderers silently work around (odd-length point lists, which are
+// truncated, shapes with fewer than three points, which are skipped, and
+// colours outside 0 to 4, which are clamped).
+func CheckLibrary(r io.Reader) ([]error, error) {
+	var rawLib map[string][]rawPattern
+	if err := json.NewDecoder(r).Decode(&rawLib); err != nil {
+		return nil, err
+	}
+
+	var problems []error
+	sigs := make([]string, 0, len(rawLib))
+	for sig := range rawLib {
+		sigs = append(sigs, sig)
+	}
+	slices.Sort(sigs)
+	for _, sig := range sigs {
+		for i, raw := range rawLib[sig] {
+			problemf := func(format string, args ...any) {
+				problems = append(problems, fmt.Errorf("%s, pattern %d: %s", sig, i+1, fmt.Sprintf(format, args...)))
+			}
+
+			if len(raw.Bounds)%2 != 0 {
+				problemf("odd number of bounds coordinates (%d)", len(raw.Bounds))
+			}
+			bounds := make([]geom.Point, 0, len(raw.Bounds)/2)
+			for j := 0; j+1 < len(raw.Bounds); j += 2 {
+				bounds = append(bounds, geom.MakePoint(raw.Bounds[j], raw.Bounds[j+1]))
+			}
+			switch {
+			case len(bounds) < 3:
+				problemf("%d bounds (want >= 3), never fits", len(bounds))
+			case geom.Dist(bounds[0], bounds[1]) < epsilon:
+				problemf("reference segment (the first two bounds) is degenerate, never fits")
+			case computeSignature(bounds) != sig:
+				problemf("bounds have signature %s, never fits", computeSignature(bounds))
+			}
+
+			for j, shape := range raw.Shapes {
+				if len(shape.Path)%2 != 0 {
+					problemf("shape %d: odd number of path coordinates (%d)", j+1, len(shape.Path))
+				}
+				if n := len(shape.Path) / 2; n < 3 {
+					problemf("shape %d: %d points (want >= 3), skipped", j+1, n)
+				}
+				if shape.Colour == DebugColour {
+					problemf("shape %d: in the debug colour", j+1)
+				} else if shape.Colour < 0 || shape.Colour > 4 {
+					problemf("shape %d: colour %d outside 0 to 4, clamped", j+1, shape.Colour)
+				}
+			}
+		}
+	}
+	return problems, nil
+}
+
+// TileSignature returns the tile's signature (see Signature) up to rotation
+// and reflection: the smallest of its rotations and those of its mirror
+// image, the same however the tile is turned or mirrored.
+func TileSignature(path []geom.Point) string {
+	return canonical(computeSignature(path))
+}
@@ -0,0 +1,90 @@
+package fillers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/irfansharif/zellij/data"
+	"github.com/irfansharif/zellij/internal/gen"
+)
+
+func TestCheckLibrary(t *testing.T) {
+	problems, err := CheckLibrary(bytes.NewReader(data.Fillers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		t.Errorf("embedded library: %v", problem)
+	}
+
+	bad := `{
+		"LLLL": [
+			{"bounds": [0, 0, 1, 0, 1, 1, 0, 1], "shapes": [{"colour": 5, "path": [0, 0, 1, 0, 1]}]},
+			{"bounds": [0, 0, 0, 0, 1, 1, 0, 1], "shapes": [{"colour": -1, "path": [0, 0, 1, 0, 1, 1]}]}
+		],
+		"VCVC": [{"bounds": [0, 0, 1, 0, 1, 1, 0, 1, 2], "shapes": []}]
+	}`
+	problems, err = CheckLibrary(strings.NewReader(bad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, problem := range problems {
+		got = append(got, problem.Error())
+	}
+	want := []string{
+		"LLLL, pattern 1: shape 1: odd number of path coordinates (5)",
+		"LLLL, pattern 1: shape 1: 2 points (want >= 3), skipped",
+		"LLLL, pattern 1: shape 1: colour 5 outside 0 to 4, clamped",
+		"LLLL, pattern 2: reference segment (the first two bounds) is degenerate, never fits",
+		"LLLL, pattern 2: shape 1: in the debug colour",
+		"VCVC, pattern 1: odd number of bounds coordinates (9)",
+		"VCVC, pattern 1: bounds have signature LLLL, never fits",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CheckLibrary() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := CheckLibrary(strings.NewReader(`{"LLLL": [`)); err == nil {
+		t.Errorf("CheckLibrary() of malformed JSON = nil error")
+	}
+}
+
+// TestDefaultCoversMotifs checks that the embedded library has patterns for
+// every tile of compositions with each of the built-in focus motifs.
+func TestDefaultCoversMotifs(t *testing.T) {
+	lib := Default()
+	for _, lattice := range []string{"Square", "Hex"} {
+		for _, motif := range gen.Foci(lattice) {
+			t.Run(fmt.Sprintf("%s/%s", lattice, motif), func(t *testing.T) {
+				style := gen.Style{Lattice: lattice, Overrides: gen.Overrides{Foci: []gen.Focus{{Motif: motif}}}}
+				unmatched := make(map[string]int64) // signature, to a seed it's in
+				generated := 0
+				for seed := int64(1); seed <= 5; seed++ {
+					features, err := gen.FeaturesFor(seed, nil, style)
+					if err != nil {
+						t.Fatal(err)
+					}
+					comp, err := gen.Generate(seed, features)
+					if err != nil {
+						continue // generating again with another seed usually works
+					}
+					generated++
+					for _, tile := range comp.Tiles {
+						if _, _, ok := lib.Match(tile.Path, Tile{}, Fixed); !ok {
+							unmatched[TileSignature(tile.Path)] = seed
+						}
+					}
+				}
+				if generated == 0 {
+					t.Fatalf("no compositions generated")
+				}
+				for sig, seed := range unmatched {
+					t.Errorf("no pattern fits %s tiles (seed %d)", sig, seed)
+				}
+			})
+		}
+	}
+}
@@ -5,25 +5,23 @@
 package fillers
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
+	"fmt"
+	"io"
 	"math"
 	"os"
-	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
 
+	"github.com/irfansharif/zellij/data"
 	"github.com/irfansharif/zellij/internal/geom"
 )
 
-const dataFilePath = "data/fillers.json"
-
-// Global library. Maps signature strings (e.g., "LCLCL...") to patterns.
-var Library = make(map[string][]Pattern)
-
-func init() {
-	if err := load(); err != nil {
-		log.Fatalf("cannot load fillers: %v", err)
-	}
-}
+// Library maps signatures (e.g., "LCLCL...", see Signature) to the patterns
+// that fill tiles with them. Libraries are shared, so treat them as read-only.
+type Library map[string][]Pattern
 
 // Shape represents a decorative polygon with a color and explicit point
 // coordinates. The Path field contains the polygon vertices as geom.Point
@@ -42,17 +40,54 @@ type Pattern struct {
 	Shapes []Shape
 }
 
-func load() error {
-	type rawShape struct {
-		Colour int       `json:"colour"`
-		Path   []float64 `json:"path"` // flat [x0,y0,x1,y1,...]
+var defaultLibrary = sync.OnceValue(func() Library {
+	lib, err := LoadLibrary(bytes.NewReader(data.Fillers))
+	if err != nil {
+		panic(fmt.Sprintf("cannot load the embedded filler library: %v", err))
 	}
+	return lib
+})
+
+// Default returns the filler library embedded in the binary, from
+// data/fillers.json.
+func Default() Library {
+	return defaultLibrary()
+}
+
+// current is the library Match and Signature use, see Use.
+var current atomic.Pointer[Library]
 
-	type rawPattern struct {
-		Bounds []float64  `json:"bounds"` // flat [x0,y0,x1,y1,...]
-		Shapes []rawShape `json:"shapes"`
+// Current returns the library tiles are filled from: Default, unless another
+// is in Use.
+func Current() Library {
+	if lib := current.Load(); lib != nil {
+		return *lib
 	}
+	return Default()
+}
+
+// Use makes Match and Signature use the given library from now on. It's safe
+// to call concurrently with them, though tiles already filled stay as they
+// are.
+func Use(lib Library) {
+	current.Store(&lib)
+}
+
+// rawShape and rawPattern are the JSON forms of Shape and Pattern.
+type rawShape struct {
+	Colour int       `json:"colour"`
+	Path   []float64 `json:"path"` // flat [x0,y0,x1,y1,...]
+}
+
+type rawPattern struct {
+	Bounds []float64  `json:"bounds"` // flat [x0,y0,x1,y1,...]
+	Shapes []rawShape `json:"shapes"`
+}
 
+// LoadLibrary reads a filler library, as JSON: an object mapping signatures
+// to lists of patterns, each with bounds and shapes (with a colour and path),
+// points stored flat, [x0,y0,x1,y1,...].
+func LoadLibrary(r io.Reader) (Library, error) {
 	convertFlatToPoints := func(flat []float64) []geom.Point {
 		if len(flat)%2 != 0 {
 			// (Handle odd-length arrays by truncating the last element.)
@@ -84,31 +119,58 @@ func load() error {
 		}
 	}
 
-	abs, _ := filepath.Abs(dataFilePath)
-	b, err := os.ReadFile(abs)
-	if err != nil {
-		return err
-	}
-
 	var rawLib map[string][]rawPattern
-	if err := json.Unmarshal(b, &rawLib); err != nil {
-		return err
+	if err := json.NewDecoder(r).Decode(&rawLib); err != nil {
+		return nil, err
 	}
 
+	lib := make(Library, len(rawLib))
 	for k, rawPatterns := range rawLib {
 		patterns := make([]Pattern, len(rawPatterns))
 		for i, p := range rawPatterns {
 			patterns[i] = convertRawPattern(p)
 		}
-		Library[k] = patterns
+		lib[k] = patterns
 	}
+	return lib, nil
+}
 
-	return nil
+// LoadLibraryFile reads the filler library at path, see LoadLibrary.
+func LoadLibraryFile(path string) (Library, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lib, err := LoadLibrary(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return lib, nil
+}
+
+// Layer returns the library with top layered over it: top's patterns replace
+// the library's for the signatures it has, and signatures it maps to no
+// patterns are dropped.
+func (l Library) Layer(top Library) Library {
+	layered := make(Library, len(l)+len(top))
+	for sig, patterns := range l {
+		layered[sig] = patterns
+	}
+	for sig, patterns := range top {
+		if len(patterns) == 0 {
+			delete(layered, sig)
+			continue
+		}
+		layered[sig] = patterns
+	}
+	return layered
 }
 
 // Signature computes the geometric signature of a polygon for filler pattern
-// matching. It tries all rotational variations of the path to find a matching
-// pattern in the library, and returns the specific matching path if found.
+// matching. It tries all rotational variations of the path (and then of its
+// mirror image, the path reversed) to find a matching pattern in the Current
+// library, and returns the specific matching path if found.
 //
 // The signature is a string where each character represents the turn angle at each vertex:
 //   - 'L': Right angle (90°) - sharp corner
@@ -130,28 +192,143 @@ func load() error {
 // This signature enables matching tiles to decorative filler patterns that fit
 // their geometric structure.
 func Signature(path []geom.Point) (string, []geom.Point, bool) {
+	return Current().Signature(path)
+}
+
+// Signature is like the package-level Signature, for signatures in the
+// library.
+func (l Library) Signature(path []geom.Point) (string, []geom.Point, bool) {
 	if len(path) == 0 {
 		return "", nil, false
 	}
 
-	// Create working copy of path for rotation.
-	alignedPath := make([]geom.Point, len(path))
-	copy(alignedPath, path)
-
-	// Try all rotational variations to find a matching pattern.
-	for rotation := 0; rotation < len(alignedPath); rotation++ {
-		signature := computeSignature(alignedPath)
-		if _, exists := Library[signature]; exists {
-			return signature, alignedPath, true
+	// Try all rotational variations to find a matching pattern, then those of
+	// the path's mirror image (its vertices reversed).
+	for _, reflected := range []bool{false, true} {
+		// Create working copy of path for rotation.
+		alignedPath := slices.Clone(path)
+		if reflected {
+			slices.Reverse(alignedPath)
 		}
+		for rotation := 0; rotation < len(alignedPath); rotation++ {
+			signature := computeSignature(alignedPath)
+			if _, exists := l[signature]; exists {
+				return signature, alignedPath, true
+			}
 
-		// Rotate path for next iteration.
-		alignedPath = append(alignedPath[1:], alignedPath[0])
+			// Rotate path for next iteration.
+			alignedPath = append(alignedPath[1:], alignedPath[0])
+		}
 	}
 
 	return "", nil, false
 }
 
+// Match finds a pattern in the Current library for the given tile path, and
+// returns it along with the transform that aligns the pattern's reference
+// segment onto the tile. It's used both by the GPU renderer and by the
+// headless exporters.
+//
+// Signatures only classify angles coarsely (45° and 60° rhombi are both
+// "VCVC"), so only patterns whose bounds, once aligned, coincide with the tile
+// are considered. Tiles that are mirror images of a pattern are filled with it
+// reflected. If several patterns fit, the CurrentSelector picks one for the
+// tile.
+func Match(path []geom.Point, t Tile) (Pattern, geom.Affine, bool) {
+	return Current().Match(path, t, CurrentSelector())
+}
+
+// Match is like the package-level Match, for patterns in the library, picked
+// by the given selector.
+func (l Library) Match(path []geom.Point, t Tile, sel Selector) (Pattern, geom.Affine, bool) {
+	if len(l) == 0 || len(path) == 0 {
+		return Pattern{}, geom.Affine{}, false
+	}
+
+	type candidate struct {
+		pattern   Pattern
+		alignment geom.Affine
+	}
+
+	// Try all rotational variations of the path, as in Signature, then those
+	// of its mirror image, which patterns are reflected to fit.
+	for _, reflected := range []bool{false, true} {
+		alignedPath := slices.Clone(path)
+		if reflected {
+			slices.Reverse(alignedPath)
+		}
+		for rotation := 0; rotation < len(alignedPath); rotation++ {
+			sig := computeSignature(alignedPath)
+
+			var candidates []candidate
+			for _, pattern := range l[sig] {
+				if len(pattern.Bounds) < 2 {
+					continue
+				}
+				// Align pattern to tile using reference segments.
+				alignment, err := align(pattern.Bounds, alignedPath, reflected)
+				if err != nil {
+					continue // degenerate pattern
+				}
+				if fits(pattern.Bounds, alignment, alignedPath) {
+					candidates = append(candidates, candidate{pattern: pattern, alignment: alignment})
+				}
+			}
+			if len(candidates) > 0 {
+				// Select a pattern.
+				c := candidates[0]
+				if len(candidates) > 1 {
+					t.Signature = sig
+					c = candidates[sel.Select(t, len(candidates))]
+				}
+				return c.pattern, c.alignment, true
+			}
+
+			// Rotate path for next iteration.
+			alignedPath = append(alignedPath[1:], alignedPath[0])
+		}
+	}
+
+	return Pattern{}, geom.Affine{}, false
+}
+
+// mirror reflects points about the x-axis.
+var mirror = geom.MakeAffine(1, 0, 0, 0, -1, 0)
+
+// align returns the transform mapping the pattern's reference segment (its
+// first two bounds) onto the path's first edge, reflecting the pattern first
+// if reflected is set: for paths reversed, to fit patterns of their mirror
+// image.
+func align(bounds, path []geom.Point, reflected bool) (geom.Affine, error) {
+	if !reflected {
+		return geom.MatchTwoSegs(bounds[0], bounds[1], path[0], path[1])
+	}
+	alignment, err := geom.MatchTwoSegs(mirror.MulPoint(bounds[0]), mirror.MulPoint(bounds[1]), path[0], path[1])
+	if err != nil {
+		return geom.Affine{}, err
+	}
+	return alignment.Mul(mirror), nil
+}
+
+// fitTolerance is how far (relative to the reference segment's length) aligned
+// pattern bounds can be from the tile's vertices and still fit it.
+const fitTolerance = 1e-2
+
+// fits reports whether the pattern bounds, aligned onto the path, coincide
+// with it vertex for vertex.
+func fits(bounds []geom.Point, alignment geom.Affine, path []geom.Point) bool {
+	if len(bounds) != len(path) {
+		return false
+	}
+	tolerance := fitTolerance * geom.Dist(path[0], path[1])
+	for i, b := range bounds {
+		if geom.Dist(alignment.MulPoint(b), path[i]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
 const epsilon = 1e-4
 
 // computeSignature computes the geometric signature of a polygon.
@@ -0,0 +1,99 @@
+package fillers
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// DebugColour is the colour index of shapes drawn in the debug colour, rather
+// than one of the palette's.
+const DebugColour = -1
+
+// Fallback returns the pattern to fill a tile no pattern in the library fits
+// with, in the tile's own coordinates. A nil Fallback leaves such tiles empty.
+type Fallback func(path []geom.Point) Pattern
+
+var (
+	// Plain fills the tile with an accent colour.
+	Plain Fallback = func(path []geom.Point) Pattern {
+		return Pattern{Bounds: path, Shapes: []Shape{{Colour: 2, Path: path}}}
+	}
+
+	// Strapwork fills the tile with a star touching its edges at their
+	// midpoints, as a band around an accent coloured center.
+	Strapwork Fallback = func(path []geom.Point) Pattern {
+		var center geom.Point
+		for _, p := range path {
+			center = center.Add(p)
+		}
+		center = center.Scale(1 / float64(len(path)))
+
+		// The star's points are the edges' midpoints, and its inner corners
+		// halfway from the tile's vertices to its center.
+		star := make([]geom.Point, 0, 2*len(path))
+		for i, p := range path {
+			next := path[(i+1)%len(path)]
+			star = append(star, p.Add(center).Scale(0.5), p.Add(next).Scale(0.5))
+		}
+		inner := make([]geom.Point, len(star))
+		for i, p := range star {
+			inner[i] = center.Add(p.Sub(center).Scale(0.7))
+		}
+		return Pattern{Bounds: path, Shapes: []Shape{
+			{Colour: 1, Path: path},
+			{Colour: 0, Path: star},
+			{Colour: 2, Path: inner},
+		}}
+	}
+
+	// Debug fills the tile with the debug colour, so gaps in the library
+	// stand out.
+	Debug Fallback = func(path []geom.Point) Pattern {
+		return Pattern{Bounds: path, Shapes: []Shape{{Colour: DebugColour, Path: path}}}
+	}
+)
+
+// Fallbacks are the fallbacks ParseFallback knows, by name.
+var Fallbacks = map[string]Fallback{
+	"none":      nil,
+	"plain":     Plain,
+	"strapwork": Strapwork,
+	"debug":     Debug,
+}
+
+// FallbackNames are the names of Fallbacks, in the order they're listed in.
+var FallbackNames = []string{"none", "plain", "strapwork", "debug"}
+
+// ParseFallback returns the fallback with the given name (see Fallbacks),
+// case-insensitively.
+func ParseFallback(name string) (Fallback, error) {
+	if fallback, ok := Fallbacks[strings.ToLower(name)]; ok {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("unknown fallback %q (want one of %s)", name, strings.Join(FallbackNames, ", "))
+}
+
+// currentFallback is the fallback in use, see UseFallback.
+var currentFallback atomic.Pointer[Fallback]
+
+// DefaultFallback names the fallback tiles no pattern fits are filled with,
+// unless another (or none) is in use.
+const DefaultFallback = "strapwork"
+
+// CurrentFallback returns the fallback tiles no pattern fits are filled with:
+// the DefaultFallback, unless another is in use.
+func CurrentFallback() Fallback {
+	if fallback := currentFallback.Load(); fallback != nil {
+		return *fallback
+	}
+	return Fallbacks[DefaultFallback]
+}
+
+// UseFallback fills tiles no pattern fits with the given fallback from now
+// on (leaving them empty if nil), see Use.
+func UseFallback(fallback Fallback) {
+	currentFallback.Store(&fallback)
+}
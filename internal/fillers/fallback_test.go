@@ -0,0 +1,29 @@
+package fillers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+func TestCurrentFallback(t *testing.T) {
+	square := []geom.Point{geom.MakePoint(0, 0), geom.MakePoint(1, 0), geom.MakePoint(1, 1), geom.MakePoint(0, 1)}
+	want, err := ParseFallback(DefaultFallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallback := CurrentFallback(); fallback == nil || !reflect.DeepEqual(fallback(square), want(square)) {
+		t.Errorf("CurrentFallback() isn't the DefaultFallback, %s", DefaultFallback)
+	}
+
+	defer currentFallback.Store(currentFallback.Load())
+	UseFallback(Plain)
+	if fallback := CurrentFallback(); fallback == nil || !reflect.DeepEqual(fallback(square), Plain(square)) {
+		t.Errorf("CurrentFallback() isn't Plain, once in use")
+	}
+	UseFallback(nil)
+	if fallback := CurrentFallback(); fallback != nil {
+		t.Errorf("CurrentFallback() isn't none (nil), once in use")
+	}
+}
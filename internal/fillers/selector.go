@@ -0,0 +1,138 @@
+package fillers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync/atomic"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// Tile describes a tile being filled, for Selectors.
+type Tile struct {
+	Seed      int64  // seed of the cluster the tile is in
+	Signature string // see Signature; set by Match
+
+	// Center is the tile's centroid, relative to the composition's center, in
+	// grid units.
+	Center geom.Point
+}
+
+// Selector picks which of the n (>= 2) patterns fitting a tile fills it,
+// returning its index. Selectors are deterministic, and safe for concurrent
+// use.
+type Selector interface {
+	Select(t Tile, n int) int
+}
+
+// SelectorFunc adapts a function to a Selector.
+type SelectorFunc func(t Tile, n int) int
+
+// Select implements Selector.
+func (f SelectorFunc) Select(t Tile, n int) int { return f(t, n) }
+
+var (
+	// Fixed fills every tile with a signature with the same pattern, whatever
+	// the cluster.
+	Fixed Selector = SelectorFunc(func(t Tile, n int) int {
+		return len(t.Signature) % n
+	})
+
+	// PerCluster fills every tile with a signature with the same pattern, one
+	// drawn from the cluster's seed.
+	PerCluster Selector = SelectorFunc(func(t Tile, n int) int {
+		return pick(n, t.Seed, canonical(t.Signature))
+	})
+
+	// PerTile draws each tile's pattern from the cluster's seed and the
+	// tile's position.
+	PerTile Selector = SelectorFunc(func(t Tile, n int) int {
+		return pick(n, t.Seed, canonical(t.Signature), quantize(t.Center.X), quantize(t.Center.Y))
+	})
+
+	// Symmetric is like PerTile, but tiles as far from the composition's
+	// center draw the same pattern, so the images of a tile under any of the
+	// composition's symmetries (mirrored ones included) do too.
+	Symmetric Selector = SelectorFunc(func(t Tile, n int) int {
+		return pick(n, t.Seed, canonical(t.Signature), quantize(math.Hypot(t.Center.X, t.Center.Y)))
+	})
+)
+
+// Selectors are the selectors ParseSelector knows, by name.
+var Selectors = map[string]Selector{
+	"fixed":     Fixed,
+	"cluster":   PerCluster,
+	"tile":      PerTile,
+	"symmetric": Symmetric,
+}
+
+// SelectorNames are the names of Selectors, in the order they're listed in.
+var SelectorNames = []string{"fixed", "cluster", "tile", "symmetric"}
+
+// ParseSelector returns the selector with the given name (see Selectors),
+// case-insensitively.
+func ParseSelector(name string) (Selector, error) {
+	if sel, ok := Selectors[strings.ToLower(name)]; ok {
+		return sel, nil
+	}
+	return nil, fmt.Errorf("unknown selector %q (want one of %s)", name, strings.Join(SelectorNames, ", "))
+}
+
+// currentSelector is the selector Match uses, see UseSelector.
+var currentSelector atomic.Pointer[Selector]
+
+// CurrentSelector returns the selector Match picks patterns with: Fixed,
+// unless another is in use.
+func CurrentSelector() Selector {
+	if sel := currentSelector.Load(); sel != nil {
+		return *sel
+	}
+	return Fixed
+}
+
+// UseSelector makes Match pick patterns with the given selector from now on,
+// see Use.
+func UseSelector(sel Selector) {
+	currentSelector.Store(&sel)
+}
+
+// pick hashes the given values (strings and integers) into an index below n.
+func pick(n int, seed int64, sig string, values ...int64) int {
+	h := fnv.New64a()
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(seed))
+	h.Write(b[:])
+	h.Write([]byte(sig))
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		h.Write(b[:])
+	}
+	return int(h.Sum64() % uint64(n))
+}
+
+// quantize rounds a coordinate (or distance) in grid units, so that ones
+// equal but for floating point error hash the same.
+func quantize(v float64) int64 {
+	return int64(math.Round(v * 1e4))
+}
+
+// canonical returns the smallest of the signature's rotations and
+// reflections, the same for a tile however it's turned or mirrored.
+func canonical(sig string) string {
+	reversed := []byte(sig)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	min := sig
+	for _, s := range []string{sig, string(reversed)} {
+		for i := range s {
+			if r := s[i:] + s[:i]; r < min {
+				min = r
+			}
+		}
+	}
+	return min
+}
@@ -0,0 +1,208 @@
+package fillers
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// randomTile returns a tile with a random seed, signature and center.
+func randomTile(rng *rand.Rand) Tile {
+	sig := make([]byte, 3+rng.Intn(10))
+	for i := range sig {
+		sig[i] = "LIVC"[rng.Intn(4)]
+	}
+	return Tile{
+		Seed:      rng.Int63(),
+		Signature: string(sig),
+		Center:    geom.MakePoint(20*rng.Float64()-10, 20*rng.Float64()-10),
+	}
+}
+
+// rotateSig returns the signature starting from its i-th vertex, as Match
+// sets it for a tile whose path starts there.
+func rotateSig(sig string, i int) string {
+	return sig[i:] + sig[:i]
+}
+
+// reverseSig returns the signature of the tile's path reversed.
+func reverseSig(sig string) string {
+	b := []byte(sig)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+func TestSelectorsDeterministic(t *testing.T) {
+	for _, name := range SelectorNames {
+		sel := Selectors[name]
+		t.Run(name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 1000; i++ {
+				tile, n := randomTile(rng), 2+rng.Intn(5)
+				got := sel.Select(tile, n)
+				if got < 0 || got >= n {
+					t.Fatalf("Select(%+v, %d) = %d, out of range", tile, n, got)
+				}
+				for j := 0; j < 3; j++ {
+					if again := sel.Select(tile, n); again != got {
+						t.Fatalf("Select(%+v, %d) = %d, then %d", tile, n, got, again)
+					}
+				}
+
+				// However the tile's path starts or runs, or its center is
+				// off by floating point error.
+				moved := tile
+				moved.Signature = reverseSig(rotateSig(tile.Signature, rng.Intn(len(tile.Signature))))
+				moved.Center = tile.Center.Add(geom.MakePoint(1e-9, -1e-9))
+				if name == "fixed" {
+					moved.Signature = tile.Signature // only its length counts
+				}
+				if again := sel.Select(moved, n); again != got {
+					t.Fatalf("Select(%+v, %d) = %d, but %d for %+v", tile, n, got, again, moved)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectorsScope(t *testing.T) {
+	const n = 5
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range []struct {
+		name string
+		sel  Selector
+		// Whether the pattern varies with the cluster's seed, and with the
+		// tile's center.
+		seed, center bool
+	}{
+		{name: "fixed", sel: Fixed},
+		{name: "cluster", sel: PerCluster, seed: true},
+		{name: "tile", sel: PerTile, seed: true, center: true},
+		{name: "symmetric", sel: Symmetric, seed: true, center: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tile := randomTile(rng)
+			bySeed, byCenter := make(map[int]bool), make(map[int]bool)
+			for i := 0; i < 100; i++ {
+				other := tile
+				other.Seed = rng.Int63()
+				bySeed[tc.sel.Select(other, n)] = true
+
+				other = tile
+				other.Center = randomTile(rng).Center
+				byCenter[tc.sel.Select(other, n)] = true
+			}
+			if got := len(bySeed) > 1; got != tc.seed {
+				t.Errorf("varies with the seed = %t, want %t", got, tc.seed)
+			}
+			if got := len(byCenter) > 1; got != tc.center {
+				t.Errorf("varies with the center = %t, want %t", got, tc.center)
+			}
+		})
+	}
+}
+
+// symmetries returns the transforms of the square and hex lattices'
+// compositions with the most symmetry (D4 and D6), about the origin.
+func symmetries() map[string][]geom.Affine {
+	ops := make(map[string][]geom.Affine)
+	for _, sym := range []struct {
+		name string
+		k    int
+	}{{"D4", 4}, {"D6", 6}} {
+		for i := 0; i < sym.k; i++ {
+			a := 2 * math.Pi * float64(i) / float64(sym.k)
+			c, s := math.Cos(a), math.Sin(a)
+			rotate := geom.MakeAffine(c, -s, 0, s, c, 0)
+			ops[sym.name] = append(ops[sym.name], rotate, rotate.Mul(mirror))
+		}
+	}
+	return ops
+}
+
+func TestSymmetricSelector(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for name, ops := range symmetries() {
+		t.Run(name, func(t *testing.T) {
+			picks := make(map[int]bool)
+			for i := 0; i < 200; i++ {
+				tile, n := randomTile(rng), 2+rng.Intn(5)
+				want := Symmetric.Select(tile, n)
+				picks[want] = true
+				for _, op := range ops {
+					image := tile
+					image.Center = op.MulPoint(tile.Center)
+					if got := Symmetric.Select(image, n); got != want {
+						t.Fatalf("Select(%+v, %d) = %d, but %d for its image %+v", tile, n, want, got, image)
+					}
+				}
+			}
+			if len(picks) < 2 {
+				t.Errorf("every tile picked pattern %d", len(picks))
+			}
+		})
+	}
+}
+
+// TestSymmetricMatch checks that Match fills the images of a tile under a
+// composition's symmetries with the same pattern, however they're turned or
+// mirrored.
+func TestSymmetricMatch(t *testing.T) {
+	// A right trapezoid (LVCL), which isn't its own mirror image, in five
+	// variants told apart by their colour.
+	trapezoid := []geom.Point{geom.MakePoint(0, 0), geom.MakePoint(2, 0), geom.MakePoint(1, 1), geom.MakePoint(0, 1)}
+	var variants []Pattern
+	for colour := 0; colour < 5; colour++ {
+		bounds := make([]geom.Point, len(trapezoid))
+		for i, p := range trapezoid {
+			bounds[i] = p.Scale(100).Add(geom.MakePoint(500, 500))
+		}
+		variants = append(variants, Pattern{Bounds: bounds, Shapes: []Shape{{Colour: colour, Path: bounds}}})
+	}
+	lib := Library{computeSignature(trapezoid): variants}
+
+	rng := rand.New(rand.NewSource(1))
+	for name, ops := range symmetries() {
+		t.Run(name, func(t *testing.T) {
+			colours := make(map[int]bool)
+			for i := 0; i < 50; i++ {
+				offset := randomTile(rng).Center
+				want := -1
+				for j, op := range ops {
+					// The tile's image, its path starting from another vertex,
+					// and wound the same way as the tile's (so reversed, for
+					// mirror images), as compositions trace them.
+					path := make([]geom.Point, len(trapezoid))
+					var center geom.Point
+					for k := range path {
+						path[k] = op.MulPoint(trapezoid[(k+j)%len(trapezoid)].Add(offset))
+						center = center.Add(path[k])
+					}
+					if op.A*op.E-op.B*op.D < 0 {
+						slices.Reverse(path)
+					}
+					tile := Tile{Seed: 7, Center: center.Scale(1 / float64(len(path)))}
+
+					pattern, _, ok := lib.Match(path, tile, Symmetric)
+					if !ok {
+						t.Fatalf("image %d of the tile at %v doesn't match", j, offset)
+					}
+					if got := pattern.Shapes[0].Colour; want == -1 {
+						want = got
+						colours[got] = true
+					} else if got != want {
+						t.Fatalf("image %d of the tile at %v filled with variant %d, want %d", j, offset, got, want)
+					}
+				}
+			}
+			if len(colours) < 2 {
+				t.Errorf("every tile filled with variant %v", colours)
+			}
+		})
+	}
+}
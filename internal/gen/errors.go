@@ -0,0 +1,25 @@
+package gen
+
+import "errors"
+
+// Errors returned by FeaturesFor, Validate and Generate, wrapped with the
+// details; check for them with errors.Is. Only invalid features are the
+// caller's to fix: the rest depend on the lines drawn, so generating again
+// with another seed usually works.
+var (
+	// ErrInvalidFeatures is returned for features (or styles) that aren't
+	// possible, see Style.Validate and Features.Validate.
+	ErrInvalidFeatures = errors.New("invalid features")
+
+	// ErrDegenerateTiling is returned when the lines drawn make no tiles,
+	// e.g. when none of them cross.
+	ErrDegenerateTiling = errors.New("degenerate tiling")
+
+	// ErrUnclosedGroup is returned when the tiles in a focus don't merge into
+	// a single closed polygon.
+	ErrUnclosedGroup = errors.New("unclosed group")
+
+	// ErrCrowdedFoci is returned when there's no room left on the grid for a
+	// focus placed at random, around the others, or when the mask cuts one.
+	ErrCrowdedFoci = errors.New("crowded foci")
+)
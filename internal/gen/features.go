@@ -0,0 +1,319 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Overrides are features set explicitly, instead of being drawn from the seed
+// (or picked by complexity), see FeaturesFor. Zero values are unset. The grid
+// side isn't settable: it follows from the line density.
+type Overrides struct {
+	LineDensity int     // density=; at least 1, and more for some foci
+	NumLines    int     // lines=; at least 1
+	Foci        []Focus // focus=; see Focus, and Foci for the motifs
+	Shimmer     int     // shimmer=; -1 (none) or >= 2
+
+	// Selection steers which lines are picked (weights=, gradient=, radial=
+	// and spacing=), see Selection.
+	Selection Selection
+}
+
+// ParseOverrides parses overrides from comma-separated key=value pairs, e.g.
+// "density=12,lines=30,focus=Sixteen,shimmer=3". Keys are density, lines,
+// focus and shimmer (none, or -1, for none), and they and focus names are
+// case-insensitive. Several foci are joined with +, and each may be pinned to
+// a grid point or ringed (see Focus), e.g. "focus=sixteen@11/11+ring(eight@5/11)".
+// Lines are selected (see Selection) with weights (one per orientation),
+// gradient (x/y), radial and spacing, e.g. "weights=1/1/4/4,radial=2,spacing=2".
+// An empty spec sets nothing. Overrides are only checked in isolation here,
+// see Style.Validate for how they fit with the rest of a style.
+func ParseOverrides(spec string) (Overrides, error) {
+	var o Overrides
+	if strings.TrimSpace(spec) == "" {
+		return o, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return Overrides{}, fmt.Errorf("invalid feature %q (want key=value)", strings.TrimSpace(field))
+		}
+		if seen[key] {
+			return Overrides{}, fmt.Errorf("feature %q set more than once", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "density":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Overrides{}, fmt.Errorf("invalid density %q (want a whole number >= 1)", value)
+			}
+			o.LineDensity = n
+		case "lines":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Overrides{}, fmt.Errorf("invalid number of lines %q (want a whole number >= 1)", value)
+			}
+			o.NumLines = n
+		case "focus":
+			for _, spec := range strings.Split(value, "+") {
+				focus, err := parseFocus(strings.TrimSpace(spec))
+				if err != nil {
+					return Overrides{}, err
+				}
+				o.Foci = append(o.Foci, focus)
+			}
+			if len(o.Foci) > 1 && slices.ContainsFunc(o.Foci, func(f Focus) bool { return f.Motif == "None" }) {
+				return Overrides{}, fmt.Errorf("invalid focus %q (none can't be combined with other foci)", value)
+			}
+		case "shimmer":
+			if strings.EqualFold(value, "none") {
+				value = "-1"
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || (n != -1 && n < 2) {
+				return Overrides{}, fmt.Errorf("invalid shimmer %q (want none, or a whole number >= 2)", value)
+			}
+			o.Shimmer = n
+		case "weights":
+			weights, err := parseFloats(value, 0)
+			if err != nil {
+				return Overrides{}, fmt.Errorf("invalid weights %q (want one number per orientation, e.g. 1/1/4/4)", value)
+			}
+			o.Selection.Weights = weights
+		case "gradient":
+			v, err := parseFloats(value, 2)
+			if err != nil {
+				return Overrides{}, fmt.Errorf("invalid gradient %q (want x/y, e.g. 2/0)", value)
+			}
+			o.Selection.Gradient.X, o.Selection.Gradient.Y = v[0], v[1]
+		case "radial":
+			v, err := parseFloats(value, 1)
+			if err != nil {
+				return Overrides{}, fmt.Errorf("invalid radial gradient %q (want a number, e.g. 2)", value)
+			}
+			o.Selection.Radial = v[0]
+		case "spacing":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Overrides{}, fmt.Errorf("invalid spacing %q (want a whole number >= 1)", value)
+			}
+			o.Selection.Spacing = n
+		default:
+			return Overrides{}, fmt.Errorf("unknown feature %q (want density, lines, focus, shimmer, weights, gradient, radial or spacing)", key)
+		}
+	}
+	return o, nil
+}
+
+// String returns the overrides in the form ParseOverrides accepts, or "" if
+// none are set.
+func (o Overrides) String() string {
+	var fields []string
+	if o.LineDensity != 0 {
+		fields = append(fields, fmt.Sprintf("density=%d", o.LineDensity))
+	}
+	if o.NumLines != 0 {
+		fields = append(fields, fmt.Sprintf("lines=%d", o.NumLines))
+	}
+	if o.Foci != nil {
+		var foci []string
+		for _, focus := range o.Foci {
+			foci = append(foci, focus.String())
+		}
+		fields = append(fields, "focus="+strings.Join(foci, "+"))
+	}
+	if o.Shimmer == -1 {
+		fields = append(fields, "shimmer=none")
+	} else if o.Shimmer != 0 {
+		fields = append(fields, fmt.Sprintf("shimmer=%d", o.Shimmer))
+	}
+	if s := o.Selection; s.Weights != nil {
+		fields = append(fields, "weights="+formatFloats(s.Weights...))
+	}
+	if s := o.Selection; s.Gradient.X != 0 || s.Gradient.Y != 0 {
+		fields = append(fields, "gradient="+formatFloats(s.Gradient.X, s.Gradient.Y))
+	}
+	if s := o.Selection; s.Radial != 0 {
+		fields = append(fields, "radial="+formatFloats(s.Radial))
+	}
+	if s := o.Selection; s.Spacing != 0 {
+		fields = append(fields, fmt.Sprintf("spacing=%d", s.Spacing))
+	}
+	return strings.Join(fields, ",")
+}
+
+// Symmetries returns the symmetries possible on the given lattice.
+func Symmetries(lattice string) []string {
+	if lattice == "Hex" {
+		return []string{"None", "D1", "D2", "C6", "D6"}
+	}
+	return []string{"None", "D1", "D2", "C4", "D4"}
+}
+
+// Validate returns an error (ErrInvalidFeatures) if the style isn't possible:
+// an unknown lattice or symmetry, or one the lattice doesn't have; a mask on a
+// periodic composition; or overrides that don't fit the lattice, the symmetry
+// or each other (e.g. a Sixteen focus on a grid too small for it).
+func (s Style) Validate() error {
+	lattice := s.Lattice
+	switch lattice {
+	case "":
+		lattice = "Square"
+	case "Square", "Hex":
+	default:
+		return invalidf("unknown lattice %q (want Square or Hex)", s.Lattice)
+	}
+	symmetric := s.Symmetry != "" && s.Symmetry != "None"
+	if symmetric && !slices.Contains(Symmetries(lattice), s.Symmetry) {
+		if s.Symmetry == "D8" {
+			return invalidf("8-fold symmetry isn't possible on either lattice, try D4 (square) or D6 (hex)")
+		}
+		return invalidf("unknown symmetry %q for the %s lattice (want one of %s)",
+			s.Symmetry, strings.ToLower(lattice), strings.Join(Symmetries(lattice), ", "))
+	}
+	if len(s.Mask) > 0 {
+		if s.Periodic {
+			return invalidf("periodic compositions can't be masked, they have no boundary")
+		}
+		if len(s.Mask) < 3 {
+			return invalidf("masks need at least three points, got %d", len(s.Mask))
+		}
+	}
+
+	o := s.Overrides
+	if o.LineDensity < 0 {
+		return invalidf("invalid density %d (want >= 1)", o.LineDensity)
+	}
+	if o.NumLines < 0 {
+		return invalidf("invalid number of lines %d (want >= 1)", o.NumLines)
+	}
+	if o.Shimmer < -1 || o.Shimmer == 1 {
+		return invalidf("invalid shimmer %d (want -1, or >= 2)", o.Shimmer)
+	}
+	if err := o.Selection.validate(lattice); err != nil {
+		return err
+	}
+	none := len(o.Foci) == 1 && o.Foci[0].Motif == "None"
+	if o.NumLines == 1 && none && !s.Medallion {
+		return invalidf("a single line crosses no others, so makes no tiles without a focus (want lines >= 2)")
+	}
+	random := 0
+	for _, focus := range o.Foci {
+		if focus.Motif == "None" {
+			if len(o.Foci) > 1 {
+				return invalidf("focus none can't be combined with other foci")
+			}
+			continue
+		}
+		if !slices.Contains(Foci(lattice), focus.Motif) {
+			return invalidf("focus %s isn't possible on the %s lattice (want one of %s)",
+				focus.Motif, strings.ToLower(lattice), strings.Join(Foci(lattice), ", "))
+		}
+		if focus.Center == nil && !focus.Ring {
+			random++
+		}
+	}
+	if (s.Medallion || symmetric) && random > 1 {
+		return invalidf("medallions and symmetric compositions center the focus placed at random, so can't have %d (pin the rest, as motif@x/y)", random)
+	}
+	if o.LineDensity != 0 {
+		g := &generator{Features: Features{LineDensity: o.LineDensity, Focus: "None", Style: s}}
+		g.adaptFocus()
+		if err := g.Features.validateFoci(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate returns an error (ErrInvalidFeatures) if the features aren't
+// possible (see Style.Validate), which Generate checks for.
+func (f Features) Validate() error {
+	if err := f.Style.Validate(); err != nil {
+		return err
+	}
+	lattice := f.Lattice
+	if lattice == "" {
+		lattice = "Square"
+	}
+	if f.LineDensity < 1 {
+		return invalidf("invalid density %d (want >= 1)", f.LineDensity)
+	}
+	if f.NumLines < 1 {
+		return invalidf("invalid number of lines %d (want >= 1)", f.NumLines)
+	}
+	if f.Shimmer < -1 || f.Shimmer == 0 || f.Shimmer == 1 {
+		return invalidf("invalid shimmer %d (want -1, or >= 2)", f.Shimmer)
+	}
+	if err := f.Selection.validate(lattice); err != nil {
+		return err
+	}
+	if f.NumLines == 1 && len(f.Foci) == 0 {
+		return invalidf("a single line crosses no others, so makes no tiles without a focus (want lines >= 2)")
+	}
+	if f.Focus != "Medallion" && !slices.Contains(Foci(lattice), f.Focus) {
+		return invalidf("focus %s isn't possible on the %s lattice", f.Focus, strings.ToLower(lattice))
+	}
+	if err := f.validateFoci(); err != nil {
+		return err
+	}
+	if side := (&generator{Features: f}).gridSide(); f.GridSide != side {
+		return invalidf("grid side %d doesn't match density %d (want %d)", f.GridSide, f.LineDensity, side)
+	}
+	return nil
+}
+
+// validateFoci returns an error (ErrInvalidFeatures) if the foci aren't on
+// the lattice, or don't fit the grid: those placed at random (or centered, for
+// medallions) need a grid large enough for them, and those pinned need to fit
+// where pinned. If the foci and density are set (see Overrides), the first
+// placed at random needs to fit around those pinned too, see placeRandom.
+func (f Features) validateFoci() error {
+	g := &generator{Features: f}
+	for i, focus := range f.Foci {
+		m := g.motif(focus.Motif)
+		if m == nil {
+			return invalidf("focus %s isn't possible on the %s lattice", focus.Motif, strings.ToLower(g.latticeName()))
+		}
+		medallion := i == 0 && f.Focus == "Medallion"
+		if focus.Center != nil && !medallion {
+			continue
+		}
+		name := strings.ToLower(focus.Motif)
+		if medallion {
+			name = "medallion"
+		}
+		if f.LineDensity < m.min {
+			return invalidf("density %d is too small for a %s focus (want >= %d)", f.LineDensity, name, m.min)
+		}
+		if medallion && g.lattice() == squareLattice && f.LineDensity%2 == 0 {
+			return invalidf("density %d can't have a centered focus on the square lattice (want an odd one)", f.LineDensity)
+		}
+	}
+	p := g.newPlacer(g.allLines())
+	if err := p.placePinned(); err != nil {
+		return err
+	}
+	if f.Overrides.LineDensity != 0 && f.Overrides.Foci != nil {
+		// Any draws do: the first focus placed at random fits around the
+		// pinned ones wherever they'd be drawn, or nowhere.
+		if err := p.placeRandom(rand.New(rand.NewSource(0))); errors.Is(err, ErrInvalidFeatures) {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidf returns an ErrInvalidFeatures error with the given details.
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidFeatures, fmt.Sprintf(format, args...))
+}
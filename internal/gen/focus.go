@@ -0,0 +1,606 @@
+package gen
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// Focus is a region of a composition whose tiles merge into a single, larger
+// one (a star or rosette, see Foci), made by keeping the lines around it and
+// clearing every other line from inside it.
+type Focus struct {
+	Motif string // see Foci
+
+	// Center is the grid point the focus is centered on, in grid coordinates
+	// (0 to twice the line density, see Grid); nil places it at random.
+	// Medallions and symmetric compositions center foci without one on the
+	// grid, and repeat pinned ones across it, so they stay symmetric.
+	Center *geom.Point
+
+	// Ring repeats the focus rotated about the grid's center (4 times on the
+	// square lattice, 6 on the hex one).
+	Ring bool
+}
+
+// parseFocus parses a focus in the form String returns: a motif, optionally
+// pinned to a grid point as motif@x/y, and optionally ringed, as ring(...).
+func parseFocus(spec string) (Focus, error) {
+	var focus Focus
+	if inner, ok := strings.CutPrefix(strings.ToLower(spec), "ring("); ok {
+		inner, ok = strings.CutSuffix(inner, ")")
+		if !ok {
+			return Focus{}, fmt.Errorf("invalid focus %q (want ring(motif) or ring(motif@x/y))", spec)
+		}
+		focus.Ring = true
+		spec = inner
+	}
+
+	name, at, pinned := strings.Cut(spec, "@")
+	for _, motif := range allFoci() {
+		if strings.EqualFold(name, motif) {
+			focus.Motif = motif
+		}
+	}
+	if focus.Motif == "" {
+		return Focus{}, fmt.Errorf("unknown focus %q (want one of %s)", name, strings.ToLower(strings.Join(allFoci(), ", ")))
+	}
+	if pinned {
+		xs, ys, ok := strings.Cut(at, "/")
+		x, xerr := strconv.Atoi(xs)
+		y, yerr := strconv.Atoi(ys)
+		if !ok || xerr != nil || yerr != nil || x < 0 || y < 0 {
+			return Focus{}, fmt.Errorf("invalid focus center %q (want x/y, grid coordinates >= 0)", at)
+		}
+		center := geom.MakePoint(float64(x), float64(y))
+		focus.Center = &center
+	}
+	if focus.Motif == "None" && (focus.Center != nil || focus.Ring) {
+		return Focus{}, fmt.Errorf("invalid focus %q (none can't be placed)", spec)
+	}
+	return focus, nil
+}
+
+// String returns the focus in the form ParseOverrides accepts.
+func (f Focus) String() string {
+	s := f.Motif
+	if f.Center != nil {
+		s += fmt.Sprintf("@%d/%d", int(f.Center.X), int(f.Center.Y))
+	}
+	if f.Ring {
+		s = "ring(" + s + ")"
+	}
+	return s
+}
+
+// Foci returns the focus motifs on the given lattice, named after the number
+// of points around the tile they merge into. Medallions center the lattice's
+// star (Sixteen, or Star) on the grid, see Style.Medallion.
+func Foci(lattice string) []string {
+	if lattice == "Hex" {
+		return []string{"None", "Star", "Eighteen", "TwentyFour"}
+	}
+	return []string{"None", "Eight", "Twelve", "Sixteen", "TwentyFour", "ThirtyTwo"}
+}
+
+// allFoci returns the focus motifs on either lattice.
+func allFoci() []string {
+	foci := Foci("Square")
+	for _, motif := range Foci("Hex") {
+		if !slices.Contains(foci, motif) {
+			foci = append(foci, motif)
+		}
+	}
+	return foci
+}
+
+// A motif describes how to force a focus around a center: the lines to keep,
+// and the region to clear of every other line. Its tiles are those around the
+// region's grid points with two or more kept lines through them, which merge
+// into one. Motifs may have a few shapes, fitting centers of different parity
+// on the square lattice; the first that fits a center is used.
+type motif struct {
+	shapes []shape
+
+	// min is the smallest line density the motif fits on, at random.
+	min int
+
+	// random draws a center for the motif, on grids with the given line
+	// density, as the JS does for its foci (and so with the same draws). nil
+	// picks uniformly among the centers it fits at.
+	random func(n int, rng *rand.Rand) geom.Point
+}
+
+type shape struct {
+	keep   []lineAt            // in the order they're kept
+	inside func(x, y int) bool // offsets from the center, within extent
+	extent int
+}
+
+// lineAt is the line along dir through the grid point at the given offset
+// from a focus' center.
+type lineAt struct{ dir, at geom.Point }
+
+var (
+	// On the square lattice (see createLines): horizontal, vertical and
+	// diagonal lines. Diagonals run through points with x-y (D) or x+y (A)
+	// even, so shapes take those on odd centers, and offset by one.
+	dirH, dirV, dirD, dirA = geom.Point{X: 1}, geom.Point{Y: 1}, geom.Point{X: 1, Y: 1}, geom.Point{X: 1, Y: -1}
+
+	// On the hex lattice (see createHexLines): lines with r, q, and q+r (s)
+	// constant.
+	dirR, dirQ, dirS = geom.Point{X: 1}, geom.Point{Y: 1}, geom.Point{X: -1, Y: 1}
+)
+
+// at returns the lines along dir through the given offsets (along x) from a
+// focus' center, or along y for horizontal lines.
+func at(dir geom.Point, offsets ...int) []lineAt {
+	var lines []lineAt
+	for _, offset := range offsets {
+		p := geom.MakePoint(float64(offset), 0)
+		if dir == dirH || dir == dirR {
+			p = geom.MakePoint(0, float64(offset))
+		}
+		lines = append(lines, lineAt{dir: dir, at: p})
+	}
+	return lines
+}
+
+func concat(lines ...[]lineAt) []lineAt {
+	var all []lineAt
+	for _, l := range lines {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// squareMotifs are the foci on the square lattice. Centers are odd grid
+// points, but for Eight's diamond.
+var squareMotifs = map[string]*motif{
+	// A 2x2 block of tiles, between two horizontal and vertical lines, or on
+	// the bias, between diagonals.
+	"Eight": {
+		shapes: []shape{{
+			keep:   concat(at(dirV, 1, -1), at(dirH, 1, -1)),
+			inside: func(x, y int) bool { return abs(x) <= 1 && abs(y) <= 1 },
+			extent: 1,
+		}, {
+			keep:   concat(at(dirA, 1, -1), at(dirD, -1, 1)),
+			inside: func(x, y int) bool { return abs(x)+abs(y) <= 1 },
+			extent: 1,
+		}},
+		min: 2,
+		random: func(n int, rng *rand.Rand) geom.Point {
+			if rng.Float64() < 0.5 {
+				ax := int(rng.Float64() * float64(n))
+				ay := int(rng.Float64() * float64(n))
+				return geom.MakePoint(float64(2*ay+1), float64(2*ax+1))
+			}
+			a := int(rng.Float64() * float64(n))
+			b := int(rng.Float64()*float64(n-1)) + 1
+			if rng.Float64() < 0.5 {
+				return geom.MakePoint(float64(2*a+1), float64(2*b))
+			}
+			return geom.MakePoint(float64(2*b), float64(2*a+1))
+		},
+	},
+	// Eight's block, and the diagonals through its center.
+	"Twelve": {
+		shapes: []shape{{
+			keep:   concat(at(dirV, 1, -1), at(dirH, 1, -1), at(dirD, 0), at(dirA, 0)),
+			inside: func(x, y int) bool { return abs(x) <= 1 && abs(y) <= 1 },
+			extent: 1,
+		}},
+		min: 2,
+	},
+	// An eight-pointed star, the square between two horizontal and vertical
+	// lines over the diamond between diagonals.
+	"Sixteen": {
+		shapes: []shape{{
+			keep:   concat(at(dirA, 4, -4), at(dirD, -4, 4), at(dirV, 3, -3), at(dirH, 3, -3)),
+			inside: func(x, y int) bool { return max(abs(x), abs(y)) <= 3 || abs(x)+abs(y) <= 4 },
+			extent: 4,
+		}},
+		min: 5,
+		random: func(n int, rng *rand.Rand) geom.Point {
+			ax := int(rng.Float64()*float64(n-4)) + 2
+			ay := int(rng.Float64()*float64(n-4)) + 2
+			return geom.MakePoint(float64(2*ay+1), float64(2*ax+1))
+		},
+	},
+	// Two nested squares, crossed by diagonals.
+	"TwentyFour": {
+		shapes: []shape{{
+			keep:   concat(at(dirV, 3, 1, -1, -3), at(dirH, 3, 1, -1, -3), at(dirA, 2, -2), at(dirD, -2, 2)),
+			inside: func(x, y int) bool { return max(abs(x), abs(y)) <= 3 },
+			extent: 3,
+		}},
+		min: 3,
+	},
+	// Sixteen's star, with every line doubled.
+	"ThirtyTwo": {
+		shapes: []shape{{
+			keep:   concat(at(dirA, 4, 2, -2, -4), at(dirD, -4, -2, 2, 4), at(dirV, 3, 1, -1, -3), at(dirH, 3, 1, -1, -3)),
+			inside: func(x, y int) bool { return max(abs(x), abs(y)) <= 3 || abs(x)+abs(y) <= 4 },
+			extent: 4,
+		}},
+		min: 5,
+	},
+}
+
+// hexMotifs are the foci on the hex lattice.
+var hexMotifs = map[string]*motif{
+	// The hexagram of the six lines one step away from the center, with
+	// twelve simple crossings: the six grid points around the center, and the
+	// six star points beyond them. Their tiles (rhombi) merge into a regular
+	// hexagon twice the size of a single tile, which fillers decorate with a
+	// star.
+	"Star": {
+		shapes: []shape{{
+			keep: concat(at(dirR, -1, 1), at(dirQ, -1, 1), at(dirS, -1, 1)),
+			inside: func(q, r int) bool {
+				return (r >= -1 && q >= -1 && q+r <= 1) || (r <= 1 && q <= 1 && q+r >= -1)
+			},
+			extent: 2,
+		}},
+		min: 2,
+		random: func(n int, rng *rand.Rand) geom.Point {
+			// Pick a center with the lines two steps away from it in bounds.
+			var centers []geom.Point
+			for r := 0; r <= 2*n; r++ {
+				for q := 0; q <= 2*n; q++ {
+					if hexDistance(q-n, r-n) <= n-2 {
+						centers = append(centers, geom.MakePoint(float64(q), float64(r)))
+					}
+				}
+			}
+			return centers[int(rng.Float64()*float64(len(centers)))]
+		},
+	},
+	// The lines through the center and one step away from it: a hexagon of
+	// seven crossings.
+	"Eighteen": {
+		shapes: []shape{{
+			keep:   concat(at(dirR, -1, 0, 1), at(dirQ, -1, 0, 1), at(dirS, -1, 0, 1)),
+			inside: func(q, r int) bool { return hexDistance(q, r) <= 1 },
+			extent: 1,
+		}},
+		min: 2,
+	},
+	// The lines one and two steps away from the center: Star's hexagram, in
+	// a larger one.
+	"TwentyFour": {
+		shapes: []shape{{
+			keep:   concat(at(dirR, -2, -1, 1, 2), at(dirQ, -2, -1, 1, 2), at(dirS, -2, -1, 1, 2)),
+			inside: func(q, r int) bool { return hexDistance(q, r) <= 2 },
+			extent: 2,
+		}},
+		min: 3,
+	},
+}
+
+// motif returns the named focus motif on the lattice in use, or nil if there's
+// no such motif on it.
+func (g *generator) motif(name string) *motif {
+	if g.lattice() == hexLattice {
+		return hexMotifs[name]
+	}
+	return squareMotifs[name]
+}
+
+// star returns the name of the lattice's star, which medallions center.
+func (g *generator) star() string {
+	if g.lattice() == hexLattice {
+		return "Star"
+	}
+	return "Sixteen"
+}
+
+// center returns the grid's center.
+func (g *generator) center() geom.Point {
+	n := float64(g.Features.LineDensity)
+	return geom.MakePoint(n, n)
+}
+
+// placer places foci on a grid's lines, taking the lines they keep and clear
+// out of all, the lines yet to be picked from.
+type placer struct {
+	g *generator
+
+	all    []line
+	keep   []line
+	groups [][]geom.Point
+
+	left    map[lineKey]line    // lines in all
+	kept    map[lineKey]bool    // lines in keep
+	claimed map[geom.Point]bool // grid points in the foci placed
+
+	// side is the torus' side, for periodic compositions, which lines and
+	// grid points are wrapped around (see lineKey.wrap); 0 otherwise.
+	side int
+}
+
+func (g *generator) newPlacer(all []line) *placer {
+	p := &placer{
+		g:       g,
+		all:     all,
+		left:    make(map[lineKey]line, len(all)),
+		kept:    make(map[lineKey]bool),
+		claimed: make(map[geom.Point]bool),
+	}
+	if g.Features.Periodic {
+		p.side = g.gridSide()
+	}
+	for _, l := range all {
+		if _, ok := p.left[p.key(l)]; !ok {
+			p.left[p.key(l)] = l
+		}
+	}
+	return p
+}
+
+func (p *placer) key(l line) lineKey {
+	return keyOf(l).wrap(p.side)
+}
+
+// wrap maps the grid point into the fundamental square of the torus, for
+// periodic compositions, see Grid.wrap.
+func (p *placer) wrap(pt geom.Point) geom.Point {
+	if p.side == 0 {
+		return pt
+	}
+	x, y := int(pt.X), int(pt.Y)
+	return geom.MakePoint(float64(x-floorDiv(x, p.side)*p.side), float64(y-floorDiv(y, p.side)*p.side))
+}
+
+// placement is a focus that fits at a center, see placer.fit.
+type placement struct {
+	keep   []line
+	clear  map[lineKey]bool
+	region []geom.Point
+	group  []geom.Point
+}
+
+// fit returns the placement of the motif at center c, or false if it doesn't
+// fit there: if its lines aren't on the grid (or were cleared by the foci
+// placed), or its region overlaps theirs.
+func (p *placer) fit(m *motif, c geom.Point) (placement, bool) {
+	for _, sh := range m.shapes {
+		if pl, ok := p.fitShape(sh, c); ok {
+			return pl, true
+		}
+	}
+	return placement{}, false
+}
+
+func (p *placer) fitShape(sh shape, c geom.Point) (placement, bool) {
+	pl := placement{clear: make(map[lineKey]bool)}
+	keys := make(map[lineKey]bool, len(sh.keep))
+	region := make(map[geom.Point]bool)
+	for _, k := range sh.keep {
+		l := line{pos: c.Add(k.at), dir: k.dir}
+		key := p.key(l)
+		if p.kept[key] {
+			keys[key] = true
+			continue
+		}
+		l, ok := p.left[key] // as listed in all
+		if !ok {
+			return placement{}, false
+		}
+		keys[key] = true
+		pl.keep = append(pl.keep, l)
+	}
+
+	for y := -sh.extent; y <= sh.extent; y++ {
+		for x := -sh.extent; x <= sh.extent; x++ {
+			if !sh.inside(x, y) {
+				continue
+			}
+			pt := c.Add(geom.MakePoint(float64(x), float64(y)))
+			if p.claimed[p.wrap(pt)] || region[p.wrap(pt)] {
+				return placement{}, false // overlapping another focus, or itself
+			}
+			region[p.wrap(pt)] = true
+			pl.region = append(pl.region, pt)
+
+			through := 0
+			for _, dir := range p.g.dirs() {
+				key := p.key(line{pos: pt, dir: dir})
+				switch {
+				case keys[key]:
+					through++
+				case p.kept[key]:
+					return placement{}, false // another focus' line
+				default:
+					pl.clear[key] = true
+				}
+			}
+			if through >= 2 {
+				if !p.bounded(pt) {
+					return placement{}, false
+				}
+				pl.group = append(pl.group, pt)
+			}
+		}
+	}
+	return pl, true
+}
+
+// bounded reports whether the grid point is on the grid. Masks clip the
+// composition traced instead, see clipToMask.
+func (p *placer) bounded(pt geom.Point) bool {
+	side := 2*p.g.Features.LineDensity + 1
+	if pt.X < 0 || pt.Y < 0 || int(pt.X) >= side || int(pt.Y) >= side {
+		return false
+	}
+	lat := p.g.lattice()
+	return lat.inside == nil || lat.inside(pt, side)
+}
+
+// commit places the focus.
+func (p *placer) commit(pl placement) {
+	for _, l := range pl.keep {
+		p.keep = append(p.keep, l)
+		p.kept[p.key(l)] = true
+	}
+	all := p.all[:0:0]
+	for _, l := range p.all {
+		key := p.key(l)
+		if p.kept[key] || pl.clear[key] {
+			delete(p.left, key)
+			continue
+		}
+		all = append(all, l)
+	}
+	p.all = all
+	for _, pt := range pl.region {
+		p.claimed[p.wrap(pt)] = true
+	}
+	p.groups = append(p.groups, pl.group)
+}
+
+// images returns the centers a focus at c is repeated at: its images under
+// the composition's symmetry, and for rings, the lattice's rotations about
+// the grid's center.
+func (p *placer) images(c geom.Point, ring bool) []geom.Point {
+	center := p.g.center()
+	rotations := []symOp{identity}
+	if ring {
+		rotate := squareRotate
+		if p.g.lattice() == hexLattice {
+			rotate = hexRotate
+		}
+		for op := rotate; op != identity; op = rotate.mul(op) {
+			rotations = append(rotations, op)
+		}
+	}
+	ops := p.g.symmetry()
+	if ops == nil {
+		ops = []symOp{identity}
+	}
+
+	var images []geom.Point
+	seen := make(map[geom.Point]bool)
+	for _, rotation := range rotations {
+		for _, op := range ops {
+			image := center.Add(op.apply(rotation.apply(c.Sub(center))))
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	return images
+}
+
+// place places the focus (and its images) at c, or returns false, placing
+// nothing, if any of them doesn't fit.
+func (p *placer) place(m *motif, c geom.Point, ring bool) bool {
+	images := p.images(c, ring)
+	if len(images) == 1 {
+		pl, ok := p.fit(m, c)
+		if ok {
+			p.commit(pl)
+		}
+		return ok
+	}
+
+	// Images may overlap one another, so try placing them all first.
+	trial := p.clone()
+	for _, image := range images {
+		pl, ok := trial.fit(m, image)
+		if !ok {
+			return false
+		}
+		trial.commit(pl)
+	}
+	*p = *trial
+	return true
+}
+
+// fits reports whether the focus (and its images) fit at c, see place.
+func (p *placer) fits(m *motif, c geom.Point, ring bool) bool {
+	if len(p.images(c, ring)) == 1 {
+		_, ok := p.fit(m, c)
+		return ok
+	}
+	return p.clone().place(m, c, ring)
+}
+
+func (p *placer) clone() *placer {
+	return &placer{
+		g:       p.g,
+		all:     slices.Clone(p.all),
+		keep:    slices.Clone(p.keep),
+		groups:  slices.Clone(p.groups),
+		left:    maps.Clone(p.left),
+		kept:    maps.Clone(p.kept),
+		claimed: maps.Clone(p.claimed),
+		side:    p.side,
+	}
+}
+
+// placePinned places the foci pinned to a center, returning an error
+// (ErrInvalidFeatures) for those that don't fit. Their motifs are assumed to
+// be on the lattice in use, see Features.Validate.
+func (p *placer) placePinned() error {
+	for _, focus := range p.g.Features.Foci {
+		if focus.Center == nil {
+			continue
+		}
+		if !p.place(p.g.motif(focus.Motif), *focus.Center, focus.Ring) {
+			return invalidf("focus %s doesn't fit on a grid of density %d (off the grid, on the wrong grid points, or over another focus)",
+				focus, p.g.Features.LineDensity)
+		}
+	}
+	return nil
+}
+
+// placeRandom places the foci without a center at random, after the pinned
+// ones. The first focus placed uses its motif's own draws, if any, as the JS
+// does; the rest are placed uniformly among the centers they fit at, or
+// return ErrCrowdedFoci if there are none. If there are none for the first
+// one placed at random around the pinned ones, with the foci and density set
+// (see Overrides), there never are, so that's ErrInvalidFeatures instead.
+func (p *placer) placeRandom(rng *rand.Rand) error {
+	n := p.g.Features.LineDensity
+	first := true // no focus placed at random yet
+	for i, focus := range p.g.Features.Foci {
+		if focus.Center != nil {
+			continue
+		}
+		firstRandom := first
+		first = false
+		m := p.g.motif(focus.Motif)
+		if m.random != nil && len(p.groups) == 0 && !focus.Ring {
+			if c := m.random(n, rng); p.place(m, c, false) {
+				continue
+			}
+			return fmt.Errorf("%w: focus %d (%s) doesn't fit where drawn", ErrCrowdedFoci, i, focus.Motif)
+		}
+
+		var centers []geom.Point
+		for y := 0; y <= 2*n; y++ {
+			for x := 0; x <= 2*n; x++ {
+				c := geom.MakePoint(float64(x), float64(y))
+				if p.fits(m, c, focus.Ring) {
+					centers = append(centers, c)
+				}
+			}
+		}
+		if len(centers) == 0 && firstRandom && p.g.Features.Overrides.LineDensity != 0 && p.g.Features.Overrides.Foci != nil {
+			return invalidf("focus %s doesn't fit anywhere around the pinned foci on a grid of density %d", focus, n)
+		}
+		if len(centers) == 0 {
+			return fmt.Errorf("%w: no room left for focus %d (%s)", ErrCrowdedFoci, i, focus.Motif)
+		}
+		p.place(m, centers[int(rng.Float64()*float64(len(centers)))], focus.Ring)
+	}
+	return nil
+}
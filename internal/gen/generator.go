@@ -3,7 +3,8 @@
 //
 // The algorithm works in several stages:
 //  - Create a random set of lines in 4 orientations (horizontal, vertical,
-//    ±45°) on an integer lattice.
+//    ±45°) on an integer lattice, or in 3 orientations (0°, 60°, 120°) on a
+//    triangular one.
 //  - Find all intersection points where 2+ lines meet.
 //  - Extract polygonal tiles by tracing edges around each intersection.
 //  - Merge tiles in designated "focus" regions to create larger shapes.
@@ -14,8 +15,10 @@
 package gen
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
+	"slices"
 
 	"github.com/irfansharif/zellij/internal/geom"
 )
@@ -25,8 +28,57 @@ type Features struct {
 	LineDensity int
 	NumLines    int
 	GridSide    int
-	Focus       string // None, Eight, Sixteen
+	Focus       string // None, or one of Foci (for the lattice); Medallion
 	Shimmer     int    // -1 or >=2
+	Style
+
+	// Foci are the foci to place: the one drawn, or those set (see
+	// Overrides), centered or repeated as the style needs. Focus is the
+	// first's motif, or Medallion if it's centered on the grid.
+	Foci []Focus
+
+	// Selection steers which of the remaining lines are picked, as set (see
+	// Overrides); uniformly at random by default.
+	Selection Selection
+
+	// draws is the number of values FeaturesFor drew from the seed's random
+	// stream; Generate picks it up from there, so features resolved
+	// separately generate the same composition as always.
+	draws int
+}
+
+// Style holds the features that are chosen rather than drawn from the seed;
+// setFeaturesForComplexity leaves them as is. See Validate for the ones
+// possible.
+type Style struct {
+	Lattice string // Square (default, also ""), Hex
+
+	// Symmetry the composition has about the grid's center: None (default,
+	// also ""), D1 (mirrored left to right), D2 (also top to bottom), or C4/D4
+	// (4-fold rotations, without/with mirrors) on the Square lattice and
+	// C6/D6 on the Hex one. 8-fold symmetry (D8) isn't possible: neither
+	// lattice has lines at 22.5°.
+	Symmetry string
+
+	// Medallion, if set, always centers a focus on the grid: the one set to
+	// be placed at random (see Overrides), or else a star (Sixteen on the
+	// Square lattice, Star on the Hex one). Symmetric compositions center
+	// whatever focus they pick regardless, so as not to break symmetry.
+	Medallion bool
+
+	// Periodic, if set, wraps lines around the grid's edges (as a torus), so
+	// the composition is a translation unit of an infinite wallpaper instead
+	// of a finite patch. See Composition.Period.
+	Periodic bool
+
+	// Mask, if set, is a polygon (in normalized coordinates, see
+	// BuiltinMask) the composition is clipped to, see clipToMask. Periodic
+	// compositions can't have one.
+	Mask []geom.Point
+
+	// Overrides, if set, take precedence over the features drawn from the
+	// seed (or picked by complexity).
+	Overrides Overrides
 }
 
 // Composition carries the generated tiles and boundary and mapping info.
@@ -35,25 +87,76 @@ type Composition struct {
 	Boundary []geom.Point
 	GridSide int
 	Shimmer  int
+	Style    // style it was generated with
+
+	// Period holds the translation vectors (in unit grid coordinates) the
+	// tiles repeat along, if Periodic. Periodic compositions have no
+	// boundary.
+	Period [2]geom.Point
 }
 
-// 
+// Tile is a single polygon in a composition, traced around the intersection
+// (lattice) point Vertex.
 type Tile struct {
 	Vertex geom.Point
 	Path   []geom.Point
 }
 
-// Generator implements the pattern generation algorithm.
-type Generator struct {
+// generator implements the pattern generation algorithm, for a single
+// composition. Use Generate, which is safe for concurrent use.
+type generator struct {
 	Features Features
 }
 
-func NewGenerator() *Generator {
-	return &Generator{}
+// countingSource counts the values drawn from it.
+type countingSource struct {
+	rand.Source
+	n int
+}
+
+func (s *countingSource) Int63() int64 {
+	s.n++
+	return s.Source.Int63()
 }
 
-// initFeatures initializes the features of the generator.
-func (g *Generator) initFeatures(rng *rand.Rand) {
+// FeaturesFor resolves the features a composition is generated with from the
+// given seed and complexity (see setFeaturesForComplexity), in the given
+// style, and applies its overrides. Pass them to Generate with the same seed.
+// Returns an ErrInvalidFeatures error if the style isn't possible (see
+// Style.Validate), or the features drawn in it aren't.
+func FeaturesFor(seed int64, complexity *int, style Style) (Features, error) {
+	if err := style.Validate(); err != nil {
+		return Features{}, err
+	}
+	src := &countingSource{Source: rand.NewSource(seed)}
+	g := &generator{Features: Features{Style: style}}
+	g.setFeaturesForComplexity(rand.New(src), complexity)
+	g.override()
+	g.adaptFocus()
+	g.Features.GridSide = g.gridSide()
+	g.Features.draws = src.n
+	return g.Features, g.Features.Validate()
+}
+
+// override applies the style's overrides to the features drawn.
+func (g *generator) override() {
+	o := g.Features.Overrides
+	if o.LineDensity != 0 {
+		g.Features.LineDensity = o.LineDensity
+	}
+	if o.NumLines != 0 {
+		g.Features.NumLines = o.NumLines
+	}
+	if o.Shimmer != 0 {
+		g.Features.Shimmer = o.Shimmer
+	}
+	g.Features.Selection = o.Selection
+	g.Features.Selection.Weights = slices.Clone(o.Selection.Weights)
+}
+
+// initFeatures draws the features of the generator. Foci are drawn for the
+// square lattice, see adaptFocus.
+func (g *generator) initFeatures(rng *rand.Rand) {
 	v := rng.Float64()
 	if v < 0.7 {
 		g.Features.LineDensity = 10
@@ -81,13 +184,11 @@ func (g *Generator) initFeatures(rng *rand.Rand) {
 	} else {
 		g.Features.Shimmer = int(rng.Float64()*3) + 2
 	}
-
-	g.Features.GridSide = 2*g.Features.LineDensity + 1
 }
 
-// SetFeaturesForComplexity sets features based on the given complexity level.
+// setFeaturesForComplexity sets features based on the given complexity level.
 // If complexity is nil, uses default randomization.
-func (g *Generator) SetFeaturesForComplexity(rng *rand.Rand, complexity *int) {
+func (g *generator) setFeaturesForComplexity(rng *rand.Rand, complexity *int) {
 	if complexity == nil {
 		g.initFeatures(rng)
 		return
@@ -120,61 +221,204 @@ func (g *Generator) SetFeaturesForComplexity(rng *rand.Rand, complexity *int) {
 	} else {
 		g.Features.Shimmer = min(4, 2+(*complexity-10)/10) // increases with complexity
 	}
+}
+
+// adaptFocus maps the focus picked for the square lattice onto the one for the
+// style in use, leaving the random draws (and so square lattice results)
+// unchanged, and resolves the foci to place. Foci drawn that don't fit the
+// line density set (see Overrides) are dropped.
+func (g *generator) adaptFocus() {
+	f := &g.Features
+	if g.lattice() == hexLattice && f.Focus != "None" {
+		f.Focus = "Star" // the only focus drawn on the hex lattice
+	}
+
+	foci := []Focus{{Motif: f.Focus}}
+	if f.Overrides.Foci != nil {
+		foci = slices.Clone(f.Overrides.Foci)
+	}
+	foci = slices.DeleteFunc(foci, func(focus Focus) bool { return focus.Motif == "None" })
+	f.Focus = "None"
+	if len(foci) > 0 {
+		f.Focus = foci[0].Motif
+	}
+	if f.Medallion || g.symmetry() != nil {
+		// Center the focus placed at random (there's at most one, see
+		// Style.Validate), or for medallions, the lattice's star.
+		i := slices.IndexFunc(foci, func(focus Focus) bool { return focus.Center == nil && !focus.Ring })
+		if i < 0 && f.Medallion {
+			foci, i = append([]Focus{{Motif: g.star()}}, foci...), 0
+		}
+		if i >= 0 {
+			foci[0], foci[i] = foci[i], foci[0]
+			if f.Overrides.Foci == nil {
+				foci[0].Motif = g.star() // whichever was drawn
+			}
+			f.Focus = "Medallion"
+		}
+	}
+
+	// The square lattice's foci are centered on odd grid coordinates, so
+	// medallions make the grid's center odd too.
+	odd := f.Focus == "Medallion" && g.lattice() == squareLattice
+	density := f.LineDensity
+	if odd {
+		density |= 1
+	}
+	if f.Overrides.Foci == nil && !f.Medallion && len(foci) > 0 && density < g.motif(foci[0].Motif).min {
+		f.Focus, foci = "None", nil // drawn, but doesn't fit the density set
+	} else if odd {
+		f.LineDensity = density
+	}
+	if f.Focus == "Medallion" {
+		center := g.center()
+		foci[0].Center = &center
+	}
+	f.Foci = foci
+}
+
+// gridSide returns the grid's side for the current line density. Periodic
+// grids leave out the last row and column, which would wrap onto the first.
+func (g *generator) gridSide() int {
+	if g.Features.Periodic {
+		return 2 * g.Features.LineDensity
+	}
+	return 2*g.Features.LineDensity + 1
+}
 
-	g.Features.GridSide = 2*g.Features.LineDensity + 1
+// lattice returns the lattice selected by the generator's features.
+func (g *generator) lattice() *lattice {
+	if g.Features.Lattice == "Hex" {
+		return hexLattice
+	}
+	return squareLattice
+}
+
+// latticeName returns the name of the lattice selected by the generator's
+// features.
+func (g *generator) latticeName() string {
+	if g.lattice() == hexLattice {
+		return "Hex"
+	}
+	return "Square"
 }
 
-// Generate creates a new procedural Islamic geometric pattern composition.
+// Generate creates a new procedural Islamic geometric pattern composition
+// with the given features, typically resolved by FeaturesFor from the same
+// seed. It's a pure function of its arguments, and safe for concurrent use.
+// Returns an error if the features aren't valid, or the lines drawn don't make
+// a composition; see ErrInvalidFeatures and co.
 //
 // The generation process:
-//  1. Randomly select generation parameters (line density, focus mode, etc.)
-//  2. Create random lines in 4 orientations (horizontal, vertical, ±45°)
-//  3. Mark grid cells where lines pass through
-//  4. Find all intersection points (where 2+ lines meet)
-//  5. Extract polygonal tiles by tracing edges around each intersection
-//  6. Merge tiles in designated "focus" regions to create visual focal points
-func (g *Generator) Generate(seed int64, complexity *int) Composition {
-	rng := rand.New(rand.NewSource(seed))
-	g.SetFeaturesForComplexity(rng, complexity)
-
-	lines, groups := g.createLines(g.Features.NumLines, rng)
+//  1. Create random lines in 4 orientations (horizontal, vertical, ±45°), or 3
+//     on the hex lattice (0°, 60°, 120°)
+//  2. Mark grid cells where lines pass through
+//  3. Find all intersection points (where 2+ lines meet)
+//  4. Extract polygonal tiles by tracing edges around each intersection
+//  5. Merge tiles in designated "focus" regions to create visual focal points
+func Generate(seed int64, features Features) (Composition, error) {
+	if err := features.Validate(); err != nil {
+		return Composition{}, err
+	}
+	src := rand.NewSource(seed)
+	for i := 0; i < features.draws; i++ {
+		src.Int63() // drawn by FeaturesFor
+	}
+	rng := rand.New(src)
+	g := &generator{Features: features}
+
+	lines, groups, err := g.createLines(g.Features.NumLines, rng)
+	if err != nil {
+		return Composition{}, err
+	}
+	return g.compose(lines, groups)
+}
+
+// compose traces the composition around the given lines, merging the tiles
+// of the grid points in each group.
+func (g *generator) compose(lines []line, groups [][]geom.Point) (Composition, error) {
 	grid := g.buildGrid(lines)
 	tiles, boundary := getAllTiles(grid)
-	tiles = g.mergeGroups(tiles, grid, groups)
+	if len(tiles) == 0 {
+		return Composition{}, fmt.Errorf("%w: none of the %d lines drawn cross", ErrDegenerateTiling, len(lines))
+	}
+	tiles, err := g.mergeGroups(tiles, grid, groups)
+	if err != nil {
+		return Composition{}, err
+	}
+	if len(g.Features.Mask) > 0 {
+		if tiles, boundary, err = clipToMask(tiles, boundary, len(groups), g.Features.Mask); err != nil {
+			return Composition{}, err
+		}
+	}
 
 	return Composition{
 		Tiles:    tiles,
 		Boundary: boundary,
 		GridSide: g.Features.GridSide,
 		Shimmer:  g.Features.Shimmer,
-	}
+		Style:    g.Features.Style,
+		Period:   grid.periods,
+	}, nil
 }
 
 // buildGrid creates and populates a grid with lines
-func (g *Generator) buildGrid(lines []line) *Grid {
-	grid := newGrid(g.Features.GridSide)
+func (g *generator) buildGrid(lines []line) *Grid {
+	grid := newGrid(g.Features.GridSide, g.lattice())
+	if g.Features.Periodic {
+		grid.periodic = true
+		grid.periods = grid.lat.periods(lines)
+	}
 	grid.markLines(lines)
 	return grid
 }
 
-// mergeGroups mirrors JS buildDesign group handling
-func (g *Generator) mergeGroups(tiles []Tile, grid *Grid, groups [][]geom.Point) []Tile {
+// mergeGroups mirrors JS buildDesign group handling. The merged tiles are
+// appended to the rest, in the groups' order.
+func (g *generator) mergeGroups(tiles []Tile, grid *Grid, groups [][]geom.Point) ([]Tile, error) {
 	for idx := 0; idx < len(groups); idx++ {
+		// On a torus, group points may wrap around; their tiles need moving
+		// along with them to line up with the rest of the group.
+		shifts := make(map[geom.Point]geom.Point)
 		for _, pt := range groups[idx] {
 			grid.setGroup(pt, idx)
+			if wrapped, shift := grid.wrap(pt); shift != (geom.Point{}) {
+				shifts[wrapped] = shift
+			}
+		}
+		// Groups not wholly traced wouldn't merge into the shape intended.
+		found := 0
+		for _, t := range tiles {
+			if grid.getGroup(t.Vertex) == idx {
+				found++
+			}
+		}
+		if found != len(groups[idx]) {
+			return nil, fmt.Errorf("%w: focus %d: %d of its %d grid points traced", ErrUnclosedGroup, idx, found, len(groups[idx]))
 		}
+
 		var grouptiles [][]geom.Point
 		for tidx := len(tiles) - 1; tidx >= 0; tidx-- {
 			t := tiles[tidx]
 			if grid.getGroup(t.Vertex) == idx {
-				grouptiles = append(grouptiles, t.Path)
+				path := t.Path
+				if shift, ok := shifts[t.Vertex]; ok {
+					path = make([]geom.Point, len(t.Path))
+					for i, p := range t.Path {
+						path[i] = p.Add(shift)
+					}
+				}
+				grouptiles = append(grouptiles, path)
 				tiles = append(tiles[:tidx], tiles[tidx+1:]...)
 			}
 		}
-		newtile := groupTiles(grouptiles)
+		newtile, err := groupTiles(grouptiles)
+		if err != nil {
+			return nil, fmt.Errorf("%w: focus %d: %v", ErrUnclosedGroup, idx, err)
+		}
 		tiles = append(tiles, Tile{Vertex: geom.MakePoint(0, 0), Path: newtile})
 	}
-	return tiles
+	return tiles, nil
 }
 
 // Below: a subset port of JS functions. Implementation is verbose for clarity.
@@ -184,6 +428,12 @@ type line struct{ pos, dir geom.Point }
 type Grid struct {
 	Side  int
 	cells []cell
+	lat   *lattice
+
+	// periodic grids wrap around as a torus, with tiles repeating along
+	// periods; see periodic.go.
+	periodic bool
+	periods  [2]geom.Point
 }
 
 type cell struct {
@@ -192,17 +442,31 @@ type cell struct {
 	group int
 }
 
-func newGrid(side int) *Grid {
+func newGrid(side int, lat *lattice) *Grid {
 	cells := make([]cell, side*side)
 	for i := range cells {
 		cells[i].group = -1
 	}
-	return &Grid{Side: side, cells: cells}
+	return &Grid{Side: side, cells: cells, lat: lat}
 }
 
-func (g *Grid) idx(p geom.Point) int { return int(p.Y)*g.Side + int(p.X) }
+func (g *Grid) idx(p geom.Point) int {
+	p, _ = g.wrap(p)
+	return int(p.Y)*g.Side + int(p.X)
+}
 func (g *Grid) in(p geom.Point) bool {
-	return p.X >= 0 && p.Y >= 0 && int(p.X) < g.Side && int(p.Y) < g.Side
+	if g.periodic {
+		return true
+	}
+	return g.bounded(p)
+}
+
+// bounded is like in, but ignores periodic grids wrapping around.
+func (g *Grid) bounded(p geom.Point) bool {
+	if p.X < 0 || p.Y < 0 || int(p.X) >= g.Side || int(p.Y) >= g.Side {
+		return false
+	}
+	return g.lat.inside == nil || g.lat.inside(p, g.Side)
 }
 func (g *Grid) getCell(p geom.Point) *cell    { return &g.cells[g.idx(p)] }
 func (g *Grid) addUser(p geom.Point, l line)  { c := g.getCell(p); c.users = append(c.users, l) }
@@ -219,6 +483,15 @@ func (g *Grid) markRay(l line, pos, dir geom.Point) {
 	}
 }
 func (g *Grid) markLine(l line) {
+	if g.periodic {
+		// The line wraps around, closing up after Side steps.
+		pos := l.pos
+		for i := 0; i < g.Side; i++ {
+			g.addUser(pos, l)
+			pos = pos.Add(l.dir)
+		}
+		return
+	}
 	g.markRay(l, l.pos, l.dir)
 	g.markRay(l, l.pos.Sub(l.dir), l.dir.Scale(-1))
 }
@@ -228,17 +501,64 @@ func (g *Grid) markLines(lines []line) {
 	}
 }
 
-var orderedDirs = []int{5, 2, 1, 0, 3, 6, 7, 8}
-var r22 = math.Sqrt(2.0) * 0.5
+// lattice describes the grid lines run on: the directions they can take, and
+// which grid points are in bounds.
+//
+// Directions are indexed by their integer step on the grid, (dy+1)*3+(dx+1),
+// see intDirVecs. dirVecs holds the corresponding unit vectors in unit grid
+// space, which is what tiles are traced in.
+type lattice struct {
+	orderedDirs []int        // direction indices, in angular order
+	dirVecs     []geom.Point // unit vectors, per direction index
+
+	// inside reports whether the grid point is in bounds, for grids of the
+	// given side. nil if every grid point is.
+	inside func(p geom.Point, side int) bool
+}
+
+// position returns the position of the grid point (or offset between grid
+// points) in unit grid space.
+func (lat *lattice) position(p geom.Point) geom.Point {
+	return lat.dirVecs[5].Scale(p.X).Add(lat.dirVecs[7].Scale(p.Y))
+}
+
 var intDirVecs = []geom.Point{
 	{X: -1, Y: -1}, {X: 0, Y: -1}, {X: 1, Y: -1},
 	{X: -1, Y: 0}, {X: 0, Y: 0}, {X: 1, Y: 0},
 	{X: -1, Y: 1}, {X: 0, Y: 1}, {X: 1, Y: 1},
 }
-var dirVecs = []geom.Point{
-	{X: -r22, Y: -r22}, {X: 0, Y: -1}, {X: r22, Y: -r22},
-	{X: -1, Y: 0}, {X: 0, Y: 0}, {X: 1, Y: 0},
-	{X: -r22, Y: r22}, {X: 0, Y: 1}, {X: r22, Y: r22},
+
+var r22 = math.Sqrt(2.0) * 0.5
+
+// squareLattice is the integer lattice, with lines running horizontally,
+// vertically and diagonally.
+var squareLattice = &lattice{
+	orderedDirs: []int{5, 2, 1, 0, 3, 6, 7, 8},
+	dirVecs: []geom.Point{
+		{X: -r22, Y: -r22}, {X: 0, Y: -1}, {X: r22, Y: -r22},
+		{X: -1, Y: 0}, {X: 0, Y: 0}, {X: 1, Y: 0},
+		{X: -r22, Y: r22}, {X: 0, Y: 1}, {X: r22, Y: r22},
+	},
+}
+
+var r32 = math.Sqrt(3.0) * 0.5
+
+// hexLattice is the triangular lattice, in axial coordinates: grid point (q, r)
+// sits at q*(1, 0) + r*(1/2, √3/2) in unit grid space. Lines run along the
+// three axes, at 0° (q varies), 60° (r varies) and 120° (q+r is constant),
+// giving 6- rather than 8-fold symmetry. Only grid points within a hexagon
+// centered on the grid are in bounds.
+var hexLattice = &lattice{
+	orderedDirs: []int{5, 2, 1, 3, 6, 7},
+	dirVecs: []geom.Point{
+		{}, {X: -0.5, Y: -r32}, {X: 0.5, Y: -r32},
+		{X: -1, Y: 0}, {}, {X: 1, Y: 0},
+		{X: -0.5, Y: r32}, {X: 0.5, Y: r32}, {},
+	},
+	inside: func(p geom.Point, side int) bool {
+		n := float64(side / 2)
+		return math.Abs(p.X+p.Y-2*n) <= n
+	},
 }
 
 func (g *Grid) findNeighbour(pt geom.Point, dir geom.Point) *geom.Point {
@@ -284,7 +604,9 @@ func getAllTiles(grid *Grid) ([]Tile, []geom.Point) {
 		// pop
 		a := stack[len(stack)-1]
 		stack = stack[:len(stack)-1]
-		pt := *a.pos
+		// On a torus, neighbours may lie past the grid's edges; wrap them
+		// back (see below).
+		pt, shift := grid.wrap(*a.pos)
 
 		if grid.isDrawn(pt) {
 			continue
@@ -308,9 +630,9 @@ func getAllTiles(grid *Grid) ([]Tile, []geom.Point) {
 
 		// First, compute the polygon we want to draw.
 		last := geom.MakePoint(0, 0)
-		for _, d := range orderedDirs {
+		for _, d := range grid.lat.orderedDirs {
 			if used_dirs[d] {
-				ddir := dirVecs[d]
+				ddir := grid.lat.dirVecs[d]
 				ppdir := geom.MakePoint(-ddir.Y, ddir.X)
 				npt := last.Add(ppdir)
 				pts = append(pts, last)
@@ -337,13 +659,19 @@ func getAllTiles(grid *Grid) ([]Tile, []geom.Point) {
 		for idx := 0; idx < len(pts); idx++ {
 			pts[idx] = pts[idx].Add(translation)
 		}
+		if grid.periodic {
+			// Move the tile along with its wrapped point.
+			for idx := 0; idx < len(pts); idx++ {
+				pts[idx] = pts[idx].Sub(shift)
+			}
+		}
 
 		L = append(L, Tile{Vertex: pt, Path: pts})
 
 		// Finally, recursively walk to your neighbours and tell them to
 		// draw as well.
 		vidx := 0
-		for _, d := range orderedDirs {
+		for _, d := range grid.lat.orderedDirs {
 			if used_dirs[d] {
 				neigh := grid.findNeighbour(pt, intDirVecs[d])
 				if neigh != nil {
@@ -364,205 +692,120 @@ func getAllTiles(grid *Grid) ([]Tile, []geom.Point) {
 }
 
 // createLines mirrors JS createLines structure
-func (g *Generator) createLines(num int, rng *rand.Rand) ([]line, [][]geom.Point) {
-	all_lines := []line{}
-	keep_lines := []line{}
-	groups := [][]geom.Point{}
-
-	makeLine := func(pos, dir geom.Point) line {
-		return line{pos: pos, dir: dir}
+func (g *generator) createLines(num int, rng *rand.Rand) ([]line, [][]geom.Point, error) {
+	all_lines := g.allLines()
+	orig := slices.Clone(all_lines)
+	p := g.newPlacer(all_lines)
+	if err := p.placePinned(); err != nil {
+		return nil, nil, err
 	}
-
-	makeAllLines := func(n int) {
-		// Horizontal lines, emanating from left edge
-		for i := 0; i < n+1; i++ {
-			all_lines = append(all_lines, makeLine(geom.MakePoint(0, float64(2*i)), geom.MakePoint(1, 0)))
-		}
-
-		// Vertical lines, emanating from top edge
-		for i := 0; i < n+1; i++ {
-			all_lines = append(all_lines, makeLine(geom.MakePoint(float64(2*i), 0), geom.MakePoint(0, 1)))
-		}
-
-		// Slope -1 lines.  n pointing NW, n+1 pointing SE
-		for i := 0; i < n+1; i++ {
-			all_lines = append(all_lines, makeLine(geom.MakePoint(float64(2*n), float64(2*i)), geom.MakePoint(-1, -1)))
-		}
-		for i := 0; i < n; i++ {
-			all_lines = append(all_lines, makeLine(geom.MakePoint(0, float64(2*i+2)), geom.MakePoint(1, 1)))
-		}
-
-		// Slope 1 lines.  n+1 pointing NE, n pointing SW
-		for i := 0; i < n+1; i++ {
-			all_lines = append(all_lines, makeLine(geom.MakePoint(0, float64(2*i)), geom.MakePoint(1, -1)))
-		}
-		for i := 0; i < n; i++ {
-			all_lines = append(all_lines, makeLine(geom.MakePoint(float64(2*i+2), float64(2*n)), geom.MakePoint(1, -1)))
-		}
+	if err := p.placeRandom(rng); err != nil {
+		return nil, nil, err
 	}
-
-	n := g.Features.LineDensity
-	makeAllLines(n)
-	switch g.Features.Focus {
-	case "Eight":
-		g.makeRandom2x2(n, &all_lines, &keep_lines, &groups, rng)
-	case "Sixteen":
-		g.makeRandomStar(n, &all_lines, &keep_lines, &groups, rng)
+	all_lines, keep_lines := p.all, p.keep
+	if g.Features.Periodic {
+		all_lines, keep_lines = wrapLines(orig, all_lines, keep_lines, g.Features.GridSide)
 	}
 
 	// Discount the lines you've already used.
 	num -= len(keep_lines)
 
-	for len(all_lines) > 0 && num > 0 {
-		ri := int(rng.Float64() * float64(len(all_lines)))
-		keep_lines = append(keep_lines, all_lines[ri])
-		all_lines = append(all_lines[:ri], all_lines[ri+1:]...)
-		num--
-	}
-
-	return keep_lines, groups
+	keep_lines = g.pickLines(num, all_lines, keep_lines, rng)
+	return keep_lines, p.groups, nil
 }
 
-// makeRandom2x2 mirrors JS makeRandom2x2 - exact same structure
-func (g *Generator) makeRandom2x2(n int, allLines, keepLines *[]line, groups *[][]geom.Point, rng *rand.Rand) {
-	// Remove some lines from the array so that a random 2x2 block is
-	// forced to be part of the result.  Return the vertices associated
-	// with that block.
-	if rng.Float64() < 0.5 {
-		ax := int(rng.Float64() * float64(n))
-		ay := int(rng.Float64() * float64(n))
+// allLines returns every line on the grid, in the order the JS lists them.
+func (g *generator) allLines() []line {
+	if g.lattice() == hexLattice {
+		return g.allHexLines()
+	}
 
-		// remove diagonals around 2x2 vertices at (ax,ay)
-		var rem []int
+	all_lines := []line{}
+	makeLine := func(pos, dir geom.Point) line {
+		return line{pos: pos, dir: dir}
+	}
 
-		for i := 0; i < 3; i++ {
-			k := (2*n + 2) + (n - 1) + ax - ay
-			if (k >= 2*n+2) && (k < 4*n+3) {
-				rem = append(rem, k)
-			}
-		}
+	n := g.Features.LineDensity
+	// Horizontal lines, emanating from left edge
+	for i := 0; i < n+1; i++ {
+		all_lines = append(all_lines, makeLine(geom.MakePoint(0, float64(2*i)), geom.MakePoint(1, 0)))
+	}
 
-		for i := 0; i < 3; i++ {
-			k := 4*n + 3 + i + ax + ay
-			rem = append(rem, k)
-		}
+	// Vertical lines, emanating from top edge
+	for i := 0; i < n+1; i++ {
+		all_lines = append(all_lines, makeLine(geom.MakePoint(float64(2*i), 0), geom.MakePoint(0, 1)))
+	}
 
-		// rem.reverse();
-		for i := len(rem) - 1; i >= 0; i-- {
-			*allLines = append((*allLines)[:rem[i]], (*allLines)[rem[i]+1:]...)
-		}
+	// Slope -1 lines.  n pointing NW, n+1 pointing SE
+	for i := 0; i < n+1; i++ {
+		all_lines = append(all_lines, makeLine(geom.MakePoint(float64(2*n), float64(2*i)), geom.MakePoint(-1, -1)))
+	}
+	for i := 0; i < n; i++ {
+		all_lines = append(all_lines, makeLine(geom.MakePoint(0, float64(2*i+2)), geom.MakePoint(1, 1)))
+	}
 
-		// Mark these vertices for later
-		*groups = append(*groups, []geom.Point{geom.MakePoint(float64(2*ay), float64(2*ax)), geom.MakePoint(float64(2*ay+2), float64(2*ax)),
-			geom.MakePoint(float64(2*ay), float64(2*ax+2)), geom.MakePoint(float64(2*ay+2), float64(2*ax+2))})
+	// Slope 1 lines.  n+1 pointing NE, n pointing SW
+	for i := 0; i < n+1; i++ {
+		all_lines = append(all_lines, makeLine(geom.MakePoint(0, float64(2*i)), geom.MakePoint(1, -1)))
+	}
+	for i := 0; i < n; i++ {
+		all_lines = append(all_lines, makeLine(geom.MakePoint(float64(2*i+2), float64(2*n)), geom.MakePoint(1, -1)))
+	}
+	return all_lines
+}
 
-		// Keep the necessary H and V lines
-		for _, i := range []int{n + 1 + ay + 1, n + 1 + ay, ax + 1, ax} {
-			*keepLines = append(*keepLines, (*allLines)[i])
-			*allLines = append((*allLines)[:i], (*allLines)[i+1:]...)
-		}
-	} else {
-		// on the bias.  Remove 2h,1v or 1h,2v
-		a := int(rng.Float64() * float64(n))
-		b := int(rng.Float64()*float64(n-1)) + 1
-
-		if rng.Float64() < 0.5 {
-			*allLines = append((*allLines)[:n+1+a+1], (*allLines)[n+1+a+2:]...)
-			*allLines = append((*allLines)[:n+1+a], (*allLines)[n+1+a+1:]...)
-			*allLines = append((*allLines)[:b], (*allLines)[b+1:]...)
-			*groups = append(*groups, []geom.Point{geom.MakePoint(float64(2*a+1), float64(2*b-1)), geom.MakePoint(float64(2*a+1), float64(2*b+1)),
-				geom.MakePoint(float64(2*a), float64(2*b)), geom.MakePoint(float64(2*a+2), float64(2*b))})
-
-			for _, i := range []int{4*n + a + b + 1, 4*n + a + b, 3*n - 1 + b - a, 3*n - 2 + b - a} {
-				*keepLines = append(*keepLines, (*allLines)[i])
-				*allLines = append((*allLines)[:i], (*allLines)[i+1:]...)
+// pickLines picks num lines at random from all_lines, adding them to
+// keep_lines. For symmetric compositions, lines are picked along with all
+// their images under the symmetry (so num may be overshot), which is the same
+// as picking lines on a fundamental domain and mirroring/rotating them across
+// the grid. Lines are picked uniformly unless the features' selection steers
+// them otherwise, see pickWeighted.
+func (g *generator) pickLines(num int, all_lines, keep_lines []line, rng *rand.Rand) []line {
+	ops := g.symmetry()
+	if !g.Features.Selection.uniform() {
+		var orbits [][]line
+		if ops == nil {
+			for _, l := range all_lines {
+				orbits = append(orbits, []line{l})
 			}
 		} else {
-			*allLines = append((*allLines)[:n+1+b], (*allLines)[n+1+b+1:]...)
-			*allLines = append((*allLines)[:a+1], (*allLines)[a+2:]...)
-			*allLines = append((*allLines)[:a], (*allLines)[a+1:]...)
-			*groups = append(*groups, []geom.Point{geom.MakePoint(float64(2*b), float64(2*a)), geom.MakePoint(float64(2*b), float64(2*a+2)),
-				geom.MakePoint(float64(2*b-1), float64(2*a+1)), geom.MakePoint(float64(2*b+1), float64(2*a+1))})
-
-			for _, i := range []int{4*n + a + b + 1, 4*n + a + b, 3*n + a - b, 3*n - 1 + a - b} {
-				*keepLines = append(*keepLines, (*allLines)[i])
-				*allLines = append((*allLines)[:i], (*allLines)[i+1:]...)
+			side := 0
+			if g.Features.Periodic {
+				side = g.Features.GridSide
 			}
+			orbits = lineOrbits(all_lines, ops, g.Features.LineDensity, side)
 		}
+		return g.pickWeighted(num, orbits, keep_lines, rng)
 	}
-}
-
-// makeRandomStar mirrors JS makeRandomStar - exact same structure
-func (g *Generator) makeRandomStar(n int, allLines, keepLines *[]line, groups *[][]geom.Point, rng *rand.Rand) {
-	ax := int(rng.Float64()*float64(n-4)) + 2
-	ay := int(rng.Float64()*float64(n-4)) + 2
-
-	var plan = []struct {
-		idx  int
-		keep bool
-	}{
-		{idx: 4*n + 7 + ax + ay, keep: false},
-		{idx: 4*n + 6 + ax + ay, keep: true},
-		{idx: 4*n + 5 + ax + ay, keep: false},
-		{idx: 4*n + 4 + ax + ay, keep: false},
-		{idx: 4*n + 3 + ax + ay, keep: false},
-		{idx: 4*n + 2 + ax + ay, keep: true},
-		{idx: 4*n + 1 + ax + ay, keep: false},
-
-		{idx: 3*n + 5 + ax - ay, keep: false},
-		{idx: 3*n + 4 + ax - ay, keep: true},
-		{idx: 3*n + 3 + ax - ay, keep: false},
-		{idx: 3*n + 2 + ax - ay, keep: false},
-		{idx: 3*n + 1 + ax - ay, keep: false},
-		{idx: 3*n + 0 + ax - ay, keep: true},
-		{idx: 3*n - 1 + ax - ay, keep: false},
-
-		{idx: n + 1 + ay + 2, keep: true},
-		{idx: n + 1 + ay + 1, keep: false},
-		{idx: n + 1 + ay, keep: false},
-		{idx: n + 1 + ay - 1, keep: true},
-
-		{idx: ax + 2, keep: true},
-		{idx: ax + 1, keep: false},
-		{idx: ax, keep: false},
-		{idx: ax - 1, keep: true},
-	}
-
-	for _, step := range plan {
-		if step.keep {
-			*keepLines = append(*keepLines, (*allLines)[step.idx])
+	if ops == nil {
+		for len(all_lines) > 0 && num > 0 {
+			ri := int(rng.Float64() * float64(len(all_lines)))
+			keep_lines = append(keep_lines, all_lines[ri])
+			all_lines = append(all_lines[:ri], all_lines[ri+1:]...)
+			num--
 		}
-		*allLines = append((*allLines)[:step.idx], (*allLines)[step.idx+1:]...)
+		return keep_lines
 	}
 
-	*groups = append(*groups, []geom.Point{
-		geom.MakePoint(float64(2*ay+1), float64(2*ax-3)),
-
-		geom.MakePoint(float64(2*ay-2), float64(2*ax-2)),
-		geom.MakePoint(float64(2*ay), float64(2*ax-2)),
-		geom.MakePoint(float64(2*ay+2), float64(2*ax-2)),
-		geom.MakePoint(float64(2*ay+4), float64(2*ax-2)),
-
-		geom.MakePoint(float64(2*ay-2), float64(2*ax)),
-		geom.MakePoint(float64(2*ay+4), float64(2*ax)),
-
-		geom.MakePoint(float64(2*ay-3), float64(2*ax+1)),
-		geom.MakePoint(float64(2*ay+5), float64(2*ax+1)),
-
-		geom.MakePoint(float64(2*ay-2), float64(2*ax+2)),
-		geom.MakePoint(float64(2*ay+4), float64(2*ax+2)),
-
-		geom.MakePoint(float64(2*ay-2), float64(2*ax+4)),
-		geom.MakePoint(float64(2*ay), float64(2*ax+4)),
-		geom.MakePoint(float64(2*ay+2), float64(2*ax+4)),
-		geom.MakePoint(float64(2*ay+4), float64(2*ax+4)),
-
-		geom.MakePoint(float64(2*ay+1), float64(2*ax+5)),
-	})
+	side := 0
+	if g.Features.Periodic {
+		side = g.Features.GridSide
+	}
+	orbits := lineOrbits(all_lines, ops, g.Features.LineDensity, side)
+	for len(orbits) > 0 && num > 0 {
+		ri := int(rng.Float64() * float64(len(orbits)))
+		keep_lines = append(keep_lines, orbits[ri]...)
+		num -= len(orbits[ri])
+		orbits = append(orbits[:ri], orbits[ri+1:]...)
+	}
+	return keep_lines
 }
 
-// groupTiles is an EXACT port of the JS groupTiles function
-func groupTiles(tiles [][]geom.Point) []geom.Point {
+// groupTiles is a port of the JS groupTiles function, merging the tiles into
+// the polygon around them. Unlike the JS, it returns an error instead of
+// looping forever if what's left of their edges doesn't chain into a single
+// closed polygon.
+func groupTiles(tiles [][]geom.Point) ([]geom.Point, error) {
 	// Build a list of segments, eliminating matching pairs.
 	type seg struct{ p, q geom.Point }
 	var segs []seg
@@ -593,22 +836,38 @@ func groupTiles(tiles [][]geom.Point) []geom.Point {
 		}
 	}
 
-	// Now reconstruct the boundary from the remaining segments.
+	if len(segs) < 3 {
+		return nil, fmt.Errorf("%d boundary segments left", len(segs))
+	}
+
+	// Now reconstruct the boundary from the remaining segments. Each pass
+	// has to chain on one, or they don't make a single closed polygon.
 	var ret []geom.Point
 	ret = append(ret, segs[0].p)
 	last := segs[0].q
 	segs = segs[1:] // segs.splice(1) in JS removes first element
 
 	for len(segs) > 0 {
+		if geom.Dist(last, ret[0]) < 0.0001 {
+			return nil, fmt.Errorf("closed early, %d segments left over", len(segs))
+		}
+		chained := false
 		for idx := 0; idx < len(segs); idx++ {
 			if geom.Dist(segs[idx].p, last) < 0.0001 {
 				ret = append(ret, segs[idx].p)
 				last = segs[idx].q
 				segs = append(segs[:idx], segs[idx+1:]...)
+				chained = true
 				break
 			}
 		}
+		if !chained {
+			return nil, fmt.Errorf("no segment continues from %v, %d left over", last, len(segs))
+		}
+	}
+	if geom.Dist(last, ret[0]) >= 0.0001 {
+		return nil, fmt.Errorf("doesn't close, ending at %v", last)
 	}
 
-	return ret
+	return ret, nil
 }
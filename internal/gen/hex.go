@@ -0,0 +1,42 @@
+package gen
+
+import (
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// allHexLines is allLines for the hex lattice. There's a line in each of the
+// three families through every in-bounds grid point; they're listed family by
+// family.
+func (g *generator) allHexLines() []line {
+	n := g.Features.LineDensity
+	all_lines := make([]line, 0, 3*(2*n+1))
+
+	// 0° lines (r constant), emanating from the left edge.
+	for k := 0; k <= 2*n; k++ {
+		all_lines = append(all_lines, line{pos: geom.MakePoint(float64(max(0, n-k)), float64(k)), dir: geom.MakePoint(1, 0)})
+	}
+	// 60° lines (q constant), emanating from the top edge.
+	for k := 0; k <= 2*n; k++ {
+		all_lines = append(all_lines, line{pos: geom.MakePoint(float64(k), float64(max(0, n-k))), dir: geom.MakePoint(0, 1)})
+	}
+	// 120° lines (q+r constant), emanating from the top/right edges.
+	for k := 0; k <= 2*n; k++ {
+		s := n + k
+		q := min(s, 2*n)
+		all_lines = append(all_lines, line{pos: geom.MakePoint(float64(q), float64(s-q)), dir: geom.MakePoint(-1, 1)})
+	}
+	return all_lines
+}
+
+// hexDistance returns the number of steps between the origin and the given
+// (axial) grid offset.
+func hexDistance(dq, dr int) int {
+	return max(abs(dq), abs(dr), abs(dq+dr))
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
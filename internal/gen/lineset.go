@@ -0,0 +1,366 @@
+package gen
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// Line is a line of the lattice, through the grid point Pos (in grid
+// coordinates, see Focus.Center) along Dir: one of the 8 steps to a
+// neighbouring grid point (see intDirVecs), or the 6 of them the hex lattice
+// has lines along, (±1, 0), (0, ±1) and ±(-1, 1) in axial coordinates.
+type Line struct{ Pos, Dir geom.Point }
+
+// LineSet is a composition's construction lines, drawn by hand instead of
+// picked from a seed; see FromLines and LoadLineSet.
+type LineSet struct {
+	Lattice     string // Square (default, also ""), Hex
+	LineDensity int    // at least 1; the grid side follows from it
+	Periodic    bool   // see Style.Periodic
+	Shimmer     int    // -1 (none, also 0) or >= 2
+	Lines       []Line
+
+	// Foci are the grid points of each focus: their tiles merge into one. Each
+	// point needs two or more lines through it.
+	Foci [][]geom.Point
+}
+
+// dirNames name the steps lines can run along, by the compass direction they
+// point in on the square lattice (y points down).
+var dirNames = map[string]geom.Point{
+	"e": {X: 1}, "se": {X: 1, Y: 1}, "s": {Y: 1}, "sw": {X: -1, Y: 1},
+	"w": {X: -1}, "nw": {X: -1, Y: -1}, "n": {Y: -1}, "ne": {X: 1, Y: -1},
+}
+
+// FromLines creates the composition traced around the given lines, merging
+// the tiles of each focus, as Generate does for the lines it picks. Returns
+// an ErrInvalidFeatures error if the line set isn't possible (see
+// LineSet.Validate), or another of Generate's errors if its lines don't make
+// a composition.
+func FromLines(set LineSet) (Composition, error) {
+	if err := set.Validate(); err != nil {
+		return Composition{}, err
+	}
+	g := set.generator()
+	lines := make([]line, len(set.Lines))
+	for i, l := range set.Lines {
+		lines[i] = line{pos: l.Pos, dir: l.Dir}
+	}
+	return g.compose(lines, set.Foci)
+}
+
+// generator returns a generator with the line set's features.
+func (s LineSet) generator() *generator {
+	g := &generator{Features: Features{
+		LineDensity: s.LineDensity,
+		NumLines:    len(s.Lines),
+		Focus:       "None",
+		Shimmer:     s.Shimmer,
+		Style:       Style{Lattice: s.Lattice, Periodic: s.Periodic},
+	}}
+	if g.Features.Shimmer == 0 {
+		g.Features.Shimmer = -1
+	}
+	g.Features.Lattice = g.latticeName()
+	g.Features.GridSide = g.gridSide()
+	return g
+}
+
+// Validate returns an error (ErrInvalidFeatures) if the line set isn't
+// possible: an unknown lattice, lines out of bounds, along directions the
+// lattice has no lines in, off the lattice's lines (square lattice lines run
+// through even rows, columns and diagonals) or set more than once, or foci on
+// points not crossed by two lines, or in more than one focus.
+func (s LineSet) Validate() error {
+	if s.Lattice != "" && s.Lattice != "Square" && s.Lattice != "Hex" {
+		return invalidf("unknown lattice %q (want Square or Hex)", s.Lattice)
+	}
+	if s.LineDensity < 1 {
+		return invalidf("invalid density %d (want >= 1)", s.LineDensity)
+	}
+	if s.Shimmer < -1 || s.Shimmer == 1 {
+		return invalidf("invalid shimmer %d (want -1, or >= 2)", s.Shimmer)
+	}
+	if len(s.Lines) == 0 {
+		return invalidf("no lines")
+	}
+	g := s.generator()
+	grid := newGrid(g.Features.GridSide, g.lattice())
+	side := 0
+	if s.Periodic {
+		side = g.Features.GridSide
+	}
+	onGrid := func(p geom.Point) bool {
+		return p.X == math.Trunc(p.X) && p.Y == math.Trunc(p.Y) && grid.bounded(p)
+	}
+
+	index := make(map[lineKey]int, len(s.Lines))
+	for i, l := range s.Lines {
+		if !onGrid(l.Pos) {
+			return invalidf("line %d: %s isn't a grid point (want x and y from 0 to %d)", i+1, formatPoint(l.Pos), grid.Side-1)
+		}
+		if !slices.Contains(g.dirs(), l.Dir) && !slices.Contains(g.dirs(), l.Dir.Scale(-1)) {
+			return invalidf("line %d: the %s lattice has no lines along %s", i+1, strings.ToLower(g.latticeName()), formatDir(l.Dir))
+		}
+		if x, y := int(l.Pos.X), int(l.Pos.Y); g.lattice() == squareLattice &&
+			((l.Dir.Y == 0 && y%2 != 0) || (l.Dir.X == 0 && x%2 != 0) || (l.Dir.X != 0 && l.Dir.Y != 0 && (x+y)%2 != 0)) {
+			return invalidf("line %d: %s %s is off the lattice's lines (want even rows, columns and diagonals)", i+1, formatPoint(l.Pos), formatDir(l.Dir))
+		}
+		key := keyOf(line{pos: l.Pos, dir: l.Dir}).wrap(side)
+		if j, ok := index[key]; ok {
+			return invalidf("line %d is line %d again", i+1, j+1)
+		}
+		index[key] = i
+	}
+
+	focus := make(map[geom.Point]int)
+	for i, points := range s.Foci {
+		if len(points) == 0 {
+			return invalidf("focus %d has no grid points", i+1)
+		}
+		for _, p := range points {
+			if !onGrid(p) {
+				return invalidf("focus %d: %s isn't a grid point (want x and y from 0 to %d)", i+1, formatPoint(p), grid.Side-1)
+			}
+			through := 0
+			for _, dir := range g.dirs() {
+				if _, ok := index[keyOf(line{pos: p, dir: dir}).wrap(side)]; ok {
+					through++
+				}
+			}
+			if through < 2 {
+				return invalidf("focus %d: %s has %d lines through it (want >= 2)", i+1, formatPoint(p), through)
+			}
+			if j, ok := focus[p]; ok && j != i {
+				return invalidf("focus %d: %s is in focus %d too", i+1, formatPoint(p), j+1)
+			}
+			focus[p] = i
+		}
+	}
+	return nil
+}
+
+// lineSetJSON is the JSON form of a line set. Points are [x, y], and
+// directions named as in the text form.
+type lineSetJSON struct {
+	Lattice  string     `json:"lattice,omitempty"`
+	Density  int        `json:"density"`
+	Periodic bool       `json:"periodic,omitempty"`
+	Shimmer  int        `json:"shimmer,omitempty"`
+	Lines    []lineJSON `json:"lines"`
+	Foci     [][][2]int `json:"foci,omitempty"`
+}
+
+type lineJSON struct {
+	Pos [2]int `json:"pos"`
+	Dir string `json:"dir"`
+}
+
+// LoadLineSet reads a line set, from JSON (see lineSetJSON) or text, one
+// setting per line:
+//
+//	# Comments run to the end of the line.
+//	lattice square
+//	density 6
+//	periodic
+//	shimmer 3
+//	line 0/4 e
+//	line 6/0 s
+//	line 0/0 se
+//	focus 6/4 6/6
+//
+// Lines are a grid point x/y and the direction they run along from it: e, se,
+// s, sw, w, nw, n or ne on the square lattice (y points down), or a step as
+// dx/dy. Foci list their grid points, see LineSet.Foci. The line set isn't
+// checked, see LineSet.Validate.
+func LoadLineSet(r io.Reader) (LineSet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return LineSet{}, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return loadLineSetJSON(data)
+	}
+
+	var set LineSet
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		key, args := strings.ToLower(fields[0]), fields[1:]
+		if err := set.parse(key, args); err != nil {
+			return LineSet{}, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return set, scanner.Err()
+}
+
+// parse parses a setting of the text form into the line set.
+func (s *LineSet) parse(key string, args []string) error {
+	want := map[string]int{"lattice": 1, "density": 1, "periodic": 0, "shimmer": 1, "line": 2}
+	if n, ok := want[key]; ok && len(args) != n {
+		return fmt.Errorf("%s wants %d values, got %d", key, n, len(args))
+	}
+	var err error
+	switch key {
+	case "lattice":
+		s.Lattice, err = parseLattice(args[0])
+	case "density":
+		if s.LineDensity, err = strconv.Atoi(args[0]); err != nil {
+			return fmt.Errorf("invalid density %q (want a whole number)", args[0])
+		}
+	case "periodic":
+		s.Periodic = true
+	case "shimmer":
+		if strings.EqualFold(args[0], "none") {
+			args[0] = "-1"
+		}
+		if s.Shimmer, err = strconv.Atoi(args[0]); err != nil {
+			return fmt.Errorf("invalid shimmer %q (want none, or a whole number)", args[0])
+		}
+	case "line":
+		var l Line
+		if l.Pos, err = parsePoint(args[0]); err != nil {
+			return err
+		}
+		if l.Dir, err = parseDir(args[1]); err != nil {
+			return err
+		}
+		s.Lines = append(s.Lines, l)
+	case "focus":
+		var points []geom.Point
+		for _, arg := range args {
+			p, err := parsePoint(arg)
+			if err != nil {
+				return err
+			}
+			points = append(points, p)
+		}
+		s.Foci = append(s.Foci, points)
+	default:
+		return fmt.Errorf("unknown setting %q (want lattice, density, periodic, shimmer, line or focus)", key)
+	}
+	return err
+}
+
+// loadLineSetJSON parses the JSON form of a line set.
+func loadLineSetJSON(data []byte) (LineSet, error) {
+	var j lineSetJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&j); err != nil {
+		return LineSet{}, err
+	}
+	set := LineSet{LineDensity: j.Density, Periodic: j.Periodic, Shimmer: j.Shimmer}
+	var err error
+	if j.Lattice != "" {
+		if set.Lattice, err = parseLattice(j.Lattice); err != nil {
+			return LineSet{}, err
+		}
+	}
+	for i, l := range j.Lines {
+		dir, err := parseDir(l.Dir)
+		if err != nil {
+			return LineSet{}, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		set.Lines = append(set.Lines, Line{Pos: geom.MakePoint(float64(l.Pos[0]), float64(l.Pos[1])), Dir: dir})
+	}
+	for _, focus := range j.Foci {
+		var points []geom.Point
+		for _, p := range focus {
+			points = append(points, geom.MakePoint(float64(p[0]), float64(p[1])))
+		}
+		set.Foci = append(set.Foci, points)
+	}
+	return set, nil
+}
+
+// Save writes the line set in the text form LoadLineSet reads.
+func (s LineSet) Save(w io.Writer) error {
+	var b strings.Builder
+	if s.Lattice != "" {
+		fmt.Fprintf(&b, "lattice %s\n", strings.ToLower(s.Lattice))
+	}
+	fmt.Fprintf(&b, "density %d\n", s.LineDensity)
+	if s.Periodic {
+		b.WriteString("periodic\n")
+	}
+	if s.Shimmer == -1 {
+		b.WriteString("shimmer none\n")
+	} else if s.Shimmer != 0 {
+		fmt.Fprintf(&b, "shimmer %d\n", s.Shimmer)
+	}
+	for _, l := range s.Lines {
+		fmt.Fprintf(&b, "line %s %s\n", formatPoint(l.Pos), formatDir(l.Dir))
+	}
+	for _, points := range s.Foci {
+		b.WriteString("focus")
+		for _, p := range points {
+			b.WriteString(" " + formatPoint(p))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// parseLattice parses a lattice name, case-insensitively.
+func parseLattice(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "square":
+		return "Square", nil
+	case "hex":
+		return "Hex", nil
+	}
+	return "", fmt.Errorf("unknown lattice %q (want square or hex)", name)
+}
+
+// parsePoint parses a grid point, as x/y.
+func parsePoint(s string) (geom.Point, error) {
+	xs, ys, ok := strings.Cut(s, "/")
+	x, xerr := strconv.Atoi(xs)
+	y, yerr := strconv.Atoi(ys)
+	if !ok || xerr != nil || yerr != nil {
+		return geom.Point{}, fmt.Errorf("invalid grid point %q (want x/y)", s)
+	}
+	return geom.MakePoint(float64(x), float64(y)), nil
+}
+
+// formatPoint formats a grid point as parsePoint parses it.
+func formatPoint(p geom.Point) string {
+	return fmt.Sprintf("%d/%d", int(p.X), int(p.Y))
+}
+
+// parseDir parses a line direction: one of dirNames, or a step as dx/dy.
+func parseDir(s string) (geom.Point, error) {
+	if dir, ok := dirNames[strings.ToLower(s)]; ok {
+		return dir, nil
+	}
+	dir, err := parsePoint(s)
+	if err != nil || dir == (geom.Point{}) || math.Abs(dir.X) > 1 || math.Abs(dir.Y) > 1 {
+		return geom.Point{}, fmt.Errorf("invalid direction %q (want e, se, s, sw, w, nw, n or ne, or dx/dy)", s)
+	}
+	return dir, nil
+}
+
+// formatDir formats a line direction as parseDir parses it.
+func formatDir(dir geom.Point) string {
+	for name, d := range dirNames {
+		if d == dir {
+			return name
+		}
+	}
+	return formatPoint(dir)
+}
@@ -0,0 +1,250 @@
+package gen
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// star is the eight-pointed star from the README, crossed by a few more
+// lines.
+var star = LineSet{
+	LineDensity: 6,
+	Lines: []Line{
+		{Pos: geom.MakePoint(0, 4), Dir: dirNames["e"]},
+		{Pos: geom.MakePoint(0, 6), Dir: dirNames["e"]},
+		{Pos: geom.MakePoint(4, 0), Dir: dirNames["s"]},
+		{Pos: geom.MakePoint(6, 0), Dir: dirNames["s"]},
+		{Pos: geom.MakePoint(0, 10), Dir: dirNames["e"]},
+		{Pos: geom.MakePoint(8, 0), Dir: dirNames["se"]},
+	},
+	Foci: [][]geom.Point{{geom.MakePoint(4, 4), geom.MakePoint(4, 6), geom.MakePoint(6, 4), geom.MakePoint(6, 6)}},
+}
+
+func TestLoadLineSet(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+		want        LineSet
+	}{
+		{
+			name: "text",
+			input: `# An eight-pointed star, crossed by a few more lines.
+density 6
+line 0/4 e
+line 0/6 E   # directions are case-insensitive
+line 4/0 s
+
+line 6/0 0/1
+line 0/10 e
+line 8/0 se
+focus 4/4 4/6 6/4 6/6
+`,
+			want: star,
+		},
+		{
+			name: "json",
+			input: `{"density": 6, "lines": [
+				{"pos": [0, 4], "dir": "e"}, {"pos": [0, 6], "dir": "e"},
+				{"pos": [4, 0], "dir": "s"}, {"pos": [6, 0], "dir": "0/1"},
+				{"pos": [0, 10], "dir": "e"}, {"pos": [8, 0], "dir": "se"}],
+				"foci": [[[4, 4], [4, 6], [6, 4], [6, 6]]]}`,
+			want: star,
+		},
+		{
+			name:  "settings",
+			input: "lattice HEX\ndensity 2\nperiodic\nshimmer none\nline 2/2 -1/1\nfocus\n",
+			want: LineSet{
+				Lattice: "Hex", LineDensity: 2, Periodic: true, Shimmer: -1,
+				Lines: []Line{{Pos: geom.MakePoint(2, 2), Dir: geom.MakePoint(-1, 1)}},
+				Foci:  [][]geom.Point{nil},
+			},
+		},
+		{
+			name:  "json settings",
+			input: `{"lattice": "hex", "density": 2, "periodic": true, "shimmer": 3, "lines": []}`,
+			want:  LineSet{Lattice: "Hex", LineDensity: 2, Periodic: true, Shimmer: 3},
+		},
+		{
+			name:  "empty",
+			input: "# Nothing but comments.\n\n",
+			want:  LineSet{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := LoadLineSet(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("LoadLineSet() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadLineSetErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, input, err string
+	}{
+		{name: "unknown setting", input: "density 6\nwidth 3\n", err: `line 2: unknown setting "width"`},
+		{name: "missing value", input: "density\n", err: "line 1: density wants 1 values, got 0"},
+		{name: "extra value", input: "density 6\n\nline 0/4 e 1\n", err: "line 3: line wants 2 values, got 3"},
+		{name: "value to periodic", input: "periodic yes\n", err: "line 1: periodic wants 0 values, got 1"},
+		{name: "density", input: "density six\n", err: `line 1: invalid density "six"`},
+		{name: "shimmer", input: "# Comment.\nshimmer some\n", err: `line 2: invalid shimmer "some"`},
+		{name: "lattice", input: "lattice triangle\n", err: `line 1: unknown lattice "triangle"`},
+		{name: "point", input: "density 6\nline 0-4 e\n", err: `line 2: invalid grid point "0-4"`},
+		{name: "point coordinate", input: "line 0/y e\n", err: `line 1: invalid grid point "0/y"`},
+		{name: "direction", input: "line 0/4 east\n", err: `line 1: invalid direction "east"`},
+		{name: "null step", input: "line 0/4 0/0\n", err: `line 1: invalid direction "0/0"`},
+		{name: "long step", input: "line 0/4 2/0\n", err: `line 1: invalid direction "2/0"`},
+		{name: "focus point", input: "line 0/4 e\nline 4/0 s\nfocus 4/4 4\n", err: `line 3: invalid grid point "4"`},
+		{name: "json syntax", input: `{"density": 6,}`, err: "invalid character"},
+		{name: "json field", input: `{"density": 6, "width": 3}`, err: `unknown field "width"`},
+		{name: "json lattice", input: `{"lattice": "triangle"}`, err: `unknown lattice "triangle"`},
+		{name: "json direction", input: `{"lines": [{"pos": [0, 4], "dir": "e"}, {"pos": [4, 0], "dir": "down"}]}`, err: `line 2: invalid direction "down"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadLineSet(strings.NewReader(tc.input))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("LoadLineSet() = %v, want an error containing %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestLineSetValidate(t *testing.T) {
+	if err := star.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(s *LineSet)
+		err    string
+	}{
+		{name: "lattice", modify: func(s *LineSet) { s.Lattice = "Triangle" }, err: `unknown lattice "Triangle"`},
+		{name: "density", modify: func(s *LineSet) { s.LineDensity = 0 }, err: "invalid density 0"},
+		{name: "shimmer", modify: func(s *LineSet) { s.Shimmer = 1 }, err: "invalid shimmer 1"},
+		{name: "no lines", modify: func(s *LineSet) { s.Lines = nil }, err: "no lines"},
+		{
+			name:   "off the grid",
+			modify: func(s *LineSet) { s.Lines[2].Pos = geom.MakePoint(14, 0) },
+			err:    "line 3: 14/0 isn't a grid point (want x and y from 0 to 12)",
+		},
+		{
+			name:   "between grid points",
+			modify: func(s *LineSet) { s.Lines[0].Pos = geom.MakePoint(0, 4.5) },
+			err:    "line 1: 0/4 isn't a grid point",
+		},
+		{
+			name: "direction",
+			modify: func(s *LineSet) {
+				s.Lattice, s.LineDensity = "Hex", 2
+				s.Lines = []Line{{Pos: geom.MakePoint(2, 2), Dir: dirNames["e"]}, {Pos: geom.MakePoint(2, 2), Dir: dirNames["se"]}}
+				s.Foci = nil
+			},
+			err: "line 2: the hex lattice has no lines along se",
+		},
+		{
+			name:   "odd row",
+			modify: func(s *LineSet) { s.Lines[1].Pos = geom.MakePoint(0, 5) },
+			err:    "line 2: 0/5 e is off the lattice's lines",
+		},
+		{
+			name:   "odd diagonal",
+			modify: func(s *LineSet) { s.Lines[5].Pos = geom.MakePoint(7, 0) },
+			err:    "line 6: 7/0 se is off the lattice's lines",
+		},
+		{
+			name:   "repeated",
+			modify: func(s *LineSet) { s.Lines[4] = Line{Pos: geom.MakePoint(12, 6), Dir: dirNames["w"]} },
+			err:    "line 5 is line 2 again",
+		},
+		{
+			name: "repeated across the torus",
+			modify: func(s *LineSet) {
+				s.Periodic = true
+				s.Lines[4] = Line{Pos: geom.MakePoint(2, 0), Dir: dirNames["se"]}
+				s.Lines[5] = Line{Pos: geom.MakePoint(0, 10), Dir: dirNames["se"]}
+			},
+			err: "line 6 is line 5 again",
+		},
+		{name: "empty focus", modify: func(s *LineSet) { s.Foci = append(s.Foci, nil) }, err: "focus 2 has no grid points"},
+		{
+			name:   "focus off the grid",
+			modify: func(s *LineSet) { s.Foci[0][3] = geom.MakePoint(6, 13) },
+			err:    "focus 1: 6/13 isn't a grid point",
+		},
+		{
+			name:   "focus off the lines",
+			modify: func(s *LineSet) { s.Foci[0] = append(s.Foci[0], geom.MakePoint(4, 8)) },
+			err:    "focus 1: 4/8 has 1 lines through it (want >= 2)",
+		},
+		{
+			name:   "overlapping foci",
+			modify: func(s *LineSet) { s.Foci = append(s.Foci, []geom.Point{geom.MakePoint(4, 10), geom.MakePoint(6, 6)}) },
+			err:    "focus 2: 6/6 is in focus 1 too",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := star
+			s.Lines = append([]Line(nil), star.Lines...)
+			s.Foci = [][]geom.Point{append([]geom.Point(nil), star.Foci[0]...)}
+			tc.modify(&s)
+			err := s.Validate()
+			if !errors.Is(err, ErrInvalidFeatures) || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Validate() = %v, want an ErrInvalidFeatures error containing %q", err, tc.err)
+			}
+			if _, ferr := FromLines(s); ferr == nil || ferr.Error() != err.Error() {
+				t.Errorf("FromLines() = %v, want %v", ferr, err)
+			}
+		})
+	}
+}
+
+func TestLineSetSave(t *testing.T) {
+	for _, s := range []LineSet{
+		star,
+		{Lattice: "Hex", LineDensity: 2, Periodic: true, Shimmer: -1, Lines: []Line{{Pos: geom.MakePoint(2, 2), Dir: geom.MakePoint(-1, 1)}}},
+		{LineDensity: 3, Shimmer: 4, Lines: []Line{{Pos: geom.MakePoint(0, 0), Dir: dirNames["se"]}}},
+	} {
+		var b strings.Builder
+		if err := s.Save(&b); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadLineSet(strings.NewReader(b.String()))
+		if err != nil {
+			t.Fatalf("loading saved line set %q: %v", b.String(), err)
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("saved as %q, loaded as %+v, want %+v", b.String(), got, s)
+		}
+	}
+}
+
+func TestFromLines(t *testing.T) {
+	comp, err := FromLines(star)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The focus' four tiles merge into the star.
+	stars := 0
+	for _, tile := range comp.Tiles {
+		if len(tile.Path) == 8 {
+			stars++
+		}
+	}
+	if stars != 1 {
+		t.Errorf("FromLines() made %d eight-sided tiles, want the star", stars)
+	}
+
+	// Parallel lines that never cross make no tiles.
+	parallel := LineSet{LineDensity: 6, Lines: star.Lines[:2]}
+	if _, err := FromLines(parallel); !errors.Is(err, ErrDegenerateTiling) {
+		t.Errorf("FromLines() of parallel lines = %v, want ErrDegenerateTiling", err)
+	}
+}
@@ -0,0 +1,223 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// Masks are polygons in normalized coordinates, within [-1, 1]² and centered
+// on the origin, with y pointing down (like grid rows). Compositions are
+// clipped to them once traced, see clipToMask: the mask is scaled to the
+// largest that fits in the composition, and the tiles outside it dropped, so
+// whatever the lines drawn, what's left has the mask's shape (up to the tiles
+// along its edge).
+
+// MaskNames lists the built-in masks, see BuiltinMask.
+var MaskNames = []string{"circle", "octagon", "horseshoe", "ogee"}
+
+// maskSegments is the number of segments curves in built-in masks are
+// approximated with.
+const maskSegments = 64
+
+// BuiltinMask returns the built-in mask with the given name
+// (case-insensitively):
+//   - circle, inscribed in the grid, for medallions;
+//   - octagon, with sides along the square lattice's lines;
+//   - horseshoe, an arch whose arc continues past its widest point, narrowing
+//     towards the jambs it springs from;
+//   - ogee, a pointed arch whose sides are S-curves, convex then concave.
+//
+// Arches are doorway shapes, open at the bottom of the grid.
+func BuiltinMask(name string) ([]geom.Point, bool) {
+	var mask []geom.Point
+	switch strings.ToLower(name) {
+	case "circle":
+		for i := 0; i < maskSegments; i++ {
+			sin, cos := math.Sincos(2 * math.Pi * float64(i) / maskSegments)
+			mask = append(mask, geom.MakePoint(cos, sin))
+		}
+	case "octagon":
+		t := math.Sqrt2 - 1 // tan(22.5°)
+		mask = []geom.Point{
+			{X: 1, Y: -t}, {X: 1, Y: t}, {X: t, Y: 1}, {X: -t, Y: 1},
+			{X: -1, Y: t}, {X: -1, Y: -t}, {X: -t, Y: -1}, {X: t, Y: -1},
+		}
+	case "horseshoe":
+		// Jambs at x = ±w, and an arc of radius r centered above them,
+		// meeting them below its center.
+		const w, r, cy = 0.75, 0.9, -0.1
+		start := math.Acos(w / r) // on the right jamb, below the center
+		mask = append(mask, geom.MakePoint(w, 1))
+		for i := 0; i <= maskSegments; i++ {
+			// Sweep from the right jamb, around the top, to the left one.
+			a := start - (math.Pi+2*start)*float64(i)/maskSegments
+			sin, cos := math.Sincos(a)
+			mask = append(mask, geom.MakePoint(r*cos, cy+r*sin))
+		}
+		mask = append(mask, geom.MakePoint(-w, 1))
+	case "ogee":
+		// Jambs at x = ±w up to the springing line, then S-curves up to the
+		// apex at the top of the grid.
+		const w, spring = 0.75, 0.0
+		mask = append(mask, geom.MakePoint(w, 1))
+		for i := 0; i <= maskSegments; i++ {
+			t := float64(i) / maskSegments
+			mask = append(mask, geom.MakePoint(w*(1+math.Cos(math.Pi*t))/2, spring+(-1-spring)*t))
+		}
+		for i := maskSegments - 1; i >= 0; i-- {
+			t := float64(i) / maskSegments
+			mask = append(mask, geom.MakePoint(-w*(1+math.Cos(math.Pi*t))/2, spring+(-1-spring)*t))
+		}
+		mask = append(mask, geom.MakePoint(-w, 1))
+	default:
+		return nil, false
+	}
+	return mask, true
+}
+
+// polygonContains reports whether p is inside the polygon (by the even-odd
+// rule).
+func polygonContains(poly []geom.Point, p geom.Point) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// maskStep is the longest step, in normalized coordinates, taken along a
+// mask's edges when fitting it in a composition.
+const maskStep = 0.05
+
+// clipToMask clips the traced composition (its tiles, the last foci of which
+// are its foci, and its boundary) to the mask, returning the tiles left and
+// their boundary. The mask is centered on the composition's bounds, and scaled
+// to the largest that fits within its boundary; tiles are kept if their
+// centroid is inside it. Returns ErrCrowdedFoci if the mask cuts through a
+// focus, or ErrDegenerateTiling if the composition doesn't cover its own
+// center, leaving the mask nowhere to fit.
+func clipToMask(tiles []Tile, boundary []geom.Point, foci int, mask []geom.Point) ([]Tile, []geom.Point, error) {
+	lo, hi := boundary[0], boundary[0]
+	for _, p := range boundary {
+		lo = geom.MakePoint(math.Min(lo.X, p.X), math.Min(lo.Y, p.Y))
+		hi = geom.MakePoint(math.Max(hi.X, p.X), math.Max(hi.Y, p.Y))
+	}
+	center := lo.Add(hi).Scale(0.5)
+	if !segmentsContain(boundary, center) {
+		return nil, nil, fmt.Errorf("%w: the composition doesn't cover its center, the mask has nowhere to fit", ErrDegenerateTiling)
+	}
+
+	// Scale the mask so that no point along its edges is further out than
+	// the composition's boundary, in its direction from the center.
+	scale := math.Inf(1)
+	for i, a := range mask {
+		b := mask[(i+1)%len(mask)]
+		steps := int(math.Ceil(geom.Dist(a, b) / maskStep))
+		for j := 0; j < steps; j++ {
+			m := a.Add(b.Sub(a).Scale(float64(j) / float64(steps)))
+			if length := math.Hypot(m.X, m.Y); length > 1e-9 {
+				scale = math.Min(scale, exitDistance(boundary, center, m.Scale(1/length))/length)
+			}
+		}
+	}
+	toMask := func(p geom.Point) geom.Point { return p.Sub(center).Scale(1 / scale) }
+
+	var kept []Tile
+	for i, t := range tiles {
+		c := centroid(t.Path)
+		if focus := i - (len(tiles) - foci); focus >= 0 {
+			// Foci have to fit whole. (Their corners are pulled in a little
+			// towards their center, so those on the mask's edge count.)
+			for _, p := range t.Path {
+				if !polygonContains(mask, toMask(p.Add(c.Sub(p).Scale(1e-6)))) {
+					return nil, nil, fmt.Errorf("%w: focus %d is cut by the mask", ErrCrowdedFoci, focus)
+				}
+			}
+		}
+		if polygonContains(mask, toMask(c)) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, nil, fmt.Errorf("%w: no tiles left inside the mask", ErrDegenerateTiling)
+	}
+	return kept, outline(kept), nil
+}
+
+// outline returns the edges of the tiles not shared with another, as pairs
+// of points like getAllTiles' boundary, in the tiles' order.
+func outline(tiles []Tile) []geom.Point {
+	type edge struct{ p, q [2]int64 }
+	key := func(p, q geom.Point) edge {
+		round := func(p geom.Point) [2]int64 {
+			return [2]int64{int64(math.Round(p.X * 1e4)), int64(math.Round(p.Y * 1e4))}
+		}
+		return edge{round(p), round(q)}
+	}
+	edges := make(map[edge]bool)
+	for _, t := range tiles {
+		for i, p := range t.Path {
+			edges[key(p, t.Path[(i+1)%len(t.Path)])] = true
+		}
+	}
+	var boundary []geom.Point
+	for _, t := range tiles {
+		for i, p := range t.Path {
+			q := t.Path[(i+1)%len(t.Path)]
+			if !edges[key(q, p)] { // shared edges run the other way around the neighbour
+				boundary = append(boundary, p, q)
+			}
+		}
+	}
+	return boundary
+}
+
+// segmentsContain is polygonContains for a polygon given as its edges, pairs
+// of points like getAllTiles' boundary.
+func segmentsContain(segs []geom.Point, p geom.Point) bool {
+	inside := false
+	for i := 0; i+1 < len(segs); i += 2 {
+		a, b := segs[i], segs[i+1]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// exitDistance returns how far from p, along the unit vector dir, the ray
+// from p first crosses one of the edges (pairs of points like getAllTiles'
+// boundary).
+func exitDistance(segs []geom.Point, p, dir geom.Point) float64 {
+	nearest := math.Inf(1)
+	for i := 0; i+1 < len(segs); i += 2 {
+		a, e := segs[i], segs[i+1].Sub(segs[i])
+		// Solve p + t*dir = a + u*e, for t >= 0 and u in [0, 1].
+		denom := dir.X*e.Y - dir.Y*e.X
+		if math.Abs(denom) < 1e-12 {
+			continue // parallel
+		}
+		ap := a.Sub(p)
+		t := (ap.X*e.Y - ap.Y*e.X) / denom
+		u := (ap.X*dir.Y - ap.Y*dir.X) / denom
+		if t >= 0 && u >= 0 && u <= 1 {
+			nearest = math.Min(nearest, t)
+		}
+	}
+	return nearest
+}
+
+// centroid returns the average of the points.
+func centroid(path []geom.Point) geom.Point {
+	var c geom.Point
+	for _, p := range path {
+		c = c.Add(p)
+	}
+	return c.Scale(1 / float64(len(path)))
+}
@@ -0,0 +1,105 @@
+package gen
+
+import (
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// Periodic compositions are generated on a torus: a grid of side
+// Features.GridSide whose opposite edges are glued together, so every line
+// wraps around and closes up on itself. Unrolled onto the plane, that's an
+// infinite, periodic arrangement of lines, and tracing tiles around one copy
+// of each grid point gives a translation unit of an infinite, periodic tiling.
+//
+// Tiles are placed where their grid points are in the fundamental square
+// [0, side)², so the unit's tiles are contiguous. Moving a grid point by a
+// whole side along x (y) crosses every line not parallel to it once, which
+// moves its tile by the first (second) period vector; see lattice.periods.
+
+// wrap reduces the line's key modulo the given torus side, identifying lines
+// that are the same once wrapped around. Returns the key as is for side 0.
+func (k lineKey) wrap(side int) lineKey {
+	if side == 0 {
+		return k
+	}
+	k.offset = ((k.offset % side) + side) % side
+	return k
+}
+
+// wrapLines reduces lines generated for a bounded grid to those on a torus of
+// the given side. Lines with the same key once wrapped around are kept once.
+// orig holds allLines from before any focus was applied: lines the focus
+// removed (or kept) are removed from allLines along with their duplicates, so
+// they're not reintroduced by picking a duplicate.
+func wrapLines(orig, allLines, keepLines []line, side int) ([]line, []line) {
+	left := make(map[line]bool, len(allLines))
+	for _, l := range allLines {
+		left[l] = true
+	}
+	taken := make(map[lineKey]bool)
+	for _, l := range orig {
+		if !left[l] {
+			taken[keyOf(l).wrap(side)] = true
+		}
+	}
+
+	var keep []line
+	seen := make(map[lineKey]bool)
+	for _, l := range keepLines {
+		key := keyOf(l).wrap(side)
+		if !seen[key] {
+			seen[key] = true
+			keep = append(keep, l)
+		}
+	}
+	var all []line
+	for _, l := range allLines {
+		key := keyOf(l).wrap(side)
+		if !taken[key] && !seen[key] {
+			seen[key] = true
+			all = append(all, l)
+		}
+	}
+	return all, keep
+}
+
+// periods returns the translation vectors (in unit grid space) of the tiling
+// traced around the given lines, wrapped on a torus: how far a tile moves
+// when its grid point moves by a whole side along x and y respectively.
+//
+// Crossing a line in direction d from the side where cross(d, ·) is positive
+// to the one where it's negative moves a tile by d's dirVecs entry rotated by
+// 90° (see the edges getAllTiles traces). Moving a whole side along x crosses
+// a line with integer direction (dx, dy) |dy| times (dx times along y).
+func (lat *lattice) periods(lines []line) [2]geom.Point {
+	var px, py geom.Point
+	for _, l := range lines {
+		d := l.dir
+		v := lat.dirVecs[int((d.Y+1)*3+(d.X+1))]
+		perp := geom.MakePoint(-v.Y, v.X)
+		px = px.Add(perp.Scale(d.Y))
+		py = py.Add(perp.Scale(-d.X))
+	}
+	return [2]geom.Point{px, py}
+}
+
+// wrap maps a grid point on a torus back into the fundamental square,
+// returning it along with how far its tile moves as a result. Returns the
+// point as is if the grid doesn't wrap around.
+func (g *Grid) wrap(p geom.Point) (geom.Point, geom.Point) {
+	if !g.periodic {
+		return p, geom.Point{}
+	}
+	x, y := int(p.X), int(p.Y)
+	i, j := floorDiv(x, g.Side), floorDiv(y, g.Side)
+	wrapped := geom.MakePoint(float64(x-i*g.Side), float64(y-j*g.Side))
+	shift := g.periods[0].Scale(float64(i)).Add(g.periods[1].Scale(float64(j)))
+	return wrapped, shift
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,222 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// Selection steers which lines are picked, once foci have taken theirs. The
+// zero value picks uniformly at random, as the JS does. Picks are weighted
+// draws from the seed's random stream, so remain deterministic per seed.
+type Selection struct {
+	// Weights are the relative weights of the lattice's line orientations:
+	// horizontal, vertical and the two diagonals (falling, then rising) on
+	// the square lattice; lines with r, q and q+r constant (0°, 60°, 120°) on
+	// the hex one. nil weighs them equally.
+	Weights []float64
+
+	// Gradient skews picks across the grid: lines are e^(Gradient·p) times
+	// as likely to be picked, for p the point on them closest to the grid's
+	// center, from -1 to 1 across the grid (y pointing down). E.g. (2, 0)
+	// makes the right side denser than the left. Symmetric compositions
+	// pick lines along with their images, evening it out.
+	Gradient geom.Point
+
+	// Radial skews picks towards (> 0) or away from (< 0) the grid's border:
+	// lines are e^(Radial·|p|) times as likely to be picked, for p as above.
+	// Positive values make for a sparse center and a dense border.
+	Radial float64
+
+	// Spacing is the minimum distance between parallel lines picked (and
+	// those the foci keep), in lines: 2 leaves at least one line between
+	// them. 0 (or 1) doesn't space them.
+	Spacing int
+}
+
+// uniform reports whether the selection picks uniformly at random.
+func (s Selection) uniform() bool {
+	return s.Weights == nil && s.Gradient == (geom.Point{}) && s.Radial == 0 && s.Spacing <= 1
+}
+
+// maxSkew bounds Gradient and Radial, past which weights only overflow.
+const maxSkew = 20
+
+// validate returns an error (ErrInvalidFeatures) if the selection isn't
+// possible on the given lattice.
+func (s Selection) validate(lattice string) error {
+	if s.Weights != nil {
+		orientations := 4
+		if lattice == "Hex" {
+			orientations = 3
+		}
+		if len(s.Weights) != orientations {
+			return invalidf("%d line weights given, the %s lattice has %d orientations", len(s.Weights), strings.ToLower(lattice), orientations)
+		}
+		positive := 0
+		for _, w := range s.Weights {
+			if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+				return invalidf("invalid line weight %v (want >= 0)", w)
+			}
+			if w > 0 {
+				positive++
+			}
+		}
+		if positive < 2 {
+			return invalidf("line weights leave fewer than two orientations, so no lines picked would cross")
+		}
+	}
+	for _, v := range []float64{s.Gradient.X, s.Gradient.Y, s.Radial} {
+		if math.IsNaN(v) || math.Abs(v) > maxSkew {
+			return invalidf("invalid gradient %v (want -%d to %d)", v, maxSkew, maxSkew)
+		}
+	}
+	if s.Spacing < 0 {
+		return invalidf("invalid spacing %d (want >= 0)", s.Spacing)
+	}
+	return nil
+}
+
+// parseFloats parses n slash-separated numbers, e.g. "1/1/4/4", or any number
+// of them if n is 0.
+func parseFloats(value string, n int) ([]float64, error) {
+	fields := strings.Split(value, "/")
+	if n != 0 && len(fields) != n {
+		return nil, fmt.Errorf("want %d numbers, got %d", n, len(fields))
+	}
+	var vs []float64
+	for _, field := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// formatFloats formats numbers as parseFloats parses them.
+func formatFloats(vs ...float64) string {
+	var fields []string
+	for _, v := range vs {
+		fields = append(fields, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return strings.Join(fields, "/")
+}
+
+// dirs returns the directions of the lattice's lines, in the order of
+// Selection.Weights.
+func (g *generator) dirs() []geom.Point {
+	if g.lattice() == hexLattice {
+		return []geom.Point{dirR, dirQ, dirS}
+	}
+	return []geom.Point{dirH, dirV, dirD, dirA}
+}
+
+// orientation returns the index of the line's orientation in dirs.
+func (g *generator) orientation(l line) int {
+	key := keyOf(l)
+	for i, dir := range g.dirs() {
+		if k := keyOf(line{dir: dir}); k.dx == key.dx && k.dy == key.dy {
+			return i
+		}
+	}
+	return -1
+}
+
+// weight returns the relative weight of picking the line.
+func (g *generator) weight(l line) float64 {
+	s := g.Features.Selection
+	w := 1.0
+	if s.Weights != nil {
+		w = s.Weights[g.orientation(l)]
+	}
+	if s.Gradient == (geom.Point{}) && s.Radial == 0 {
+		return w
+	}
+
+	// The point on the line closest to the grid's center, in unit grid space
+	// (where the hex lattice's axes aren't orthogonal), relative to it.
+	lat := g.lattice()
+	pos, dir := lat.position(l.pos.Sub(g.center())), lat.position(l.dir)
+	p := pos.Sub(dir.Scale(geom.Dot(pos, dir) / geom.Dot(dir, dir)))
+	p = p.Scale(1 / float64(g.Features.LineDensity))
+	return w * math.Exp(geom.Dot(s.Gradient, p)+s.Radial*math.Hypot(p.X, p.Y))
+}
+
+// tooClose reports whether the lines are parallel, and closer than the
+// selection's spacing allows.
+func (g *generator) tooClose(l, m line) bool {
+	spacing := g.Features.Selection.Spacing
+	a, b := keyOf(l), keyOf(m)
+	if spacing <= 1 || a.dx != b.dx || a.dy != b.dy {
+		return false
+	}
+	d := abs(a.offset - b.offset)
+	if g.Features.Periodic {
+		side := g.Features.GridSide
+		d = abs(a.wrap(side).offset - b.wrap(side).offset)
+		d = min(d, side-d)
+	}
+	step := 2 // square lattice lines lie on even grid coordinates
+	if g.lattice() == hexLattice {
+		step = 1
+	}
+	return d < spacing*step
+}
+
+// pickWeighted is pickLines for non-uniform selections: orbits (single lines,
+// without symmetry) are picked with probability proportional to their
+// lines' mean weight, skipping those too close to lines already kept.
+func (g *generator) pickWeighted(num int, orbits [][]line, keep_lines []line, rng *rand.Rand) []line {
+	weights := make([]float64, len(orbits))
+	for i, orbit := range orbits {
+		for _, l := range orbit {
+			weights[i] += g.weight(l) / float64(len(orbit))
+		}
+	}
+	spaced := func(kept []line) {
+		for i, orbit := range orbits {
+			for _, l := range orbit {
+				for _, k := range kept {
+					if g.tooClose(l, k) {
+						weights[i] = 0
+					}
+				}
+			}
+		}
+	}
+	spaced(keep_lines)
+
+	for num > 0 {
+		total := 0.0
+		for _, w := range weights {
+			total += w
+		}
+		if total <= 0 {
+			break
+		}
+		r := rng.Float64() * total
+		ri := len(orbits) - 1
+		for i, w := range weights {
+			if r < w {
+				ri = i
+				break
+			}
+			r -= w
+		}
+		for weights[ri] == 0 {
+			ri-- // rounding past the end
+		}
+
+		keep_lines = append(keep_lines, orbits[ri]...)
+		num -= len(orbits[ri])
+		weights[ri] = 0
+		spaced(orbits[ri])
+	}
+	return keep_lines
+}
@@ -0,0 +1,132 @@
+package gen
+
+import (
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// symOp is a symmetry of the grid about its center: a linear map on integer
+// offsets from the center, as the matrix [a b; c d].
+type symOp struct{ a, b, c, d int }
+
+var (
+	identity = symOp{1, 0, 0, 1}
+
+	squareRotate = symOp{0, -1, 1, 0} // 90°: (x, y) → (-y, x)
+	squareMirror = symOp{-1, 0, 0, 1} // left to right: (x, y) → (-x, y)
+	squareFlip   = symOp{1, 0, 0, -1} // top to bottom: (x, y) → (x, -y)
+
+	// On the hex lattice's axial coordinates (see hexLattice).
+	hexRotate = symOp{0, -1, 1, 1}  // 60°: (q, r) → (-r, q+r)
+	hexMirror = symOp{-1, -1, 0, 1} // left to right: (q, r) → (-q-r, r)
+	hexFlip   = symOp{1, 1, 0, -1}  // top to bottom: (q, r) → (q+r, -r)
+)
+
+func (o symOp) apply(p geom.Point) geom.Point {
+	return geom.MakePoint(
+		float64(o.a)*p.X+float64(o.b)*p.Y,
+		float64(o.c)*p.X+float64(o.d)*p.Y,
+	)
+}
+
+func (o symOp) mul(p symOp) symOp {
+	return symOp{
+		a: o.a*p.a + o.b*p.c, b: o.a*p.b + o.b*p.d,
+		c: o.c*p.a + o.d*p.c, d: o.c*p.b + o.d*p.d,
+	}
+}
+
+// symmetry returns all operations of the symmetry group selected by the
+// generator's features (identity first), or nil if there's none, or it isn't
+// possible on the lattice in use.
+func (g *generator) symmetry() []symOp {
+	square := g.lattice() == squareLattice
+	mirror, flip := hexMirror, hexFlip
+	if square {
+		mirror, flip = squareMirror, squareFlip
+	}
+
+	var generators []symOp
+	switch g.Features.Symmetry {
+	case "D1":
+		generators = []symOp{mirror}
+	case "D2":
+		generators = []symOp{mirror, flip}
+	case "C4", "D4":
+		if square {
+			generators = []symOp{squareRotate}
+		}
+	case "C6", "D6":
+		if !square {
+			generators = []symOp{hexRotate}
+		}
+	}
+	if generators == nil {
+		return nil
+	}
+	if g.Features.Symmetry == "D4" || g.Features.Symmetry == "D6" {
+		generators = append(generators, mirror)
+	}
+
+	// Close the group under composition.
+	ops := []symOp{identity}
+	for i := 0; i < len(ops); i++ {
+		for _, generator := range generators {
+			op := generator.mul(ops[i])
+			found := false
+			for _, other := range ops {
+				if other == op {
+					found = true
+					break
+				}
+			}
+			if !found {
+				ops = append(ops, op)
+			}
+		}
+	}
+	return ops
+}
+
+// lineKey identifies a line irrespective of the point and (sign of the)
+// direction it's described by.
+type lineKey struct{ dx, dy, offset int }
+
+func keyOf(l line) lineKey {
+	dx, dy := int(l.dir.X), int(l.dir.Y)
+	if dx < 0 || (dx == 0 && dy < 0) {
+		dx, dy = -dx, -dy
+	}
+	x, y := int(l.pos.X), int(l.pos.Y)
+	return lineKey{dx: dx, dy: dy, offset: x*dy - y*dx}
+}
+
+// lineOrbits partitions lines into orbits under the given symmetry operations
+// about the center of a grid with the given line density: sets of lines that
+// map onto one another. Orbits are ordered by their first line in lines.
+// Images that aren't in lines are left out. Lines are identified once wrapped
+// around a torus of the given side, if non-zero.
+func lineOrbits(lines []line, ops []symOp, n, side int) [][]line {
+	center := geom.MakePoint(float64(n), float64(n))
+	index := make(map[lineKey]int, len(lines))
+	for i, l := range lines {
+		index[keyOf(l).wrap(side)] = i
+	}
+
+	var orbits [][]line
+	seen := make([]bool, len(lines))
+	for i, l := range lines {
+		if seen[i] {
+			continue
+		}
+		var orbit []line
+		for _, op := range ops {
+			image := line{pos: center.Add(op.apply(l.pos.Sub(center))), dir: op.apply(l.dir)}
+			if j, ok := index[keyOf(image).wrap(side)]; ok && !seen[j] {
+				seen[j] = true
+				orbit = append(orbit, lines[j])
+			}
+		}
+		orbits = append(orbits, orbit)
+	}
+	return orbits
+}
@@ -0,0 +1,78 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/irfansharif/zellij/internal/geom"
+)
+
+// tileAngles returns the tile's interior angles, sorted, in degrees rounded to
+// a hundredth: the same for a tile and its images, however they're turned or
+// mirrored, or their paths start.
+func tileAngles(path []geom.Point) []float64 {
+	angles := make([]float64, len(path))
+	for i, p := range path {
+		in, out := p.Sub(path[(i+len(path)-1)%len(path)]), path[(i+1)%len(path)].Sub(p)
+		turn := math.Atan2(in.X*out.Y-in.Y*out.X, in.X*out.X+in.Y*out.Y)
+		angles[i] = math.Round(18000-turn*18000/math.Pi) / 100
+	}
+	slices.Sort(angles)
+	return angles
+}
+
+func TestSymmetricCompositions(t *testing.T) {
+	for _, lattice := range []string{"Square", "Hex"} {
+		for _, symmetry := range Symmetries(lattice) {
+			if symmetry == "None" {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/%s", lattice, symmetry), func(t *testing.T) {
+				generated := 0
+				for seed := int64(1); seed <= 5; seed++ {
+					features, err := FeaturesFor(seed, nil, Style{Lattice: lattice, Symmetry: symmetry})
+					if err != nil {
+						t.Fatal(err)
+					}
+					g := &generator{Features: features}
+					ops := g.symmetry()
+					if len(ops) < 2 {
+						t.Fatalf("seed %d: %d symmetry operations", seed, len(ops))
+					}
+					comp, err := Generate(seed, features)
+					if err != nil {
+						continue // generating again with another seed usually works
+					}
+					generated++
+
+					// Tiles by their grid point, bar the foci's, merged into
+					// one at the origin.
+					tiles := make(map[geom.Point][]float64)
+					for _, tile := range comp.Tiles {
+						if tile.Vertex != (geom.Point{}) {
+							tiles[tile.Vertex] = tileAngles(tile.Path)
+						}
+					}
+					center := g.center()
+					for vertex, angles := range tiles {
+						for _, op := range ops {
+							image := op.apply(vertex.Sub(center)).Add(center)
+							if image == (geom.Point{}) {
+								continue
+							}
+							if got, ok := tiles[image]; !ok || !slices.Equal(got, angles) {
+								t.Fatalf("seed %d: the tile at %v (angles %v) maps to %v under %v, but the tile there has angles %v",
+									seed, vertex, angles, image, op, got)
+							}
+						}
+					}
+				}
+				if generated == 0 {
+					t.Fatalf("no compositions generated")
+				}
+			})
+		}
+	}
+}
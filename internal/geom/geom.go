@@ -7,7 +7,6 @@ package geom
 
 import (
 	"fmt"
-	"log"
 	"math"
 )
 
@@ -62,6 +61,21 @@ func (t Affine) MulPoint(p Point) Point {
 	}
 }
 
+// MulBox applies the affine transform to a box, returning the axis-aligned
+// bounds of the result.
+func (t Affine) MulBox(b Box) Box {
+	xmin, xmax := math.MaxFloat64, -math.MaxFloat64
+	ymin, ymax := math.MaxFloat64, -math.MaxFloat64
+	for _, corner := range []Point{
+		{b.X, b.Y}, {b.X + b.W, b.Y}, {b.X + b.W, b.Y + b.H}, {b.X, b.Y + b.H},
+	} {
+		p := t.MulPoint(corner)
+		xmin, xmax = math.Min(xmin, p.X), math.Max(xmax, p.X)
+		ymin, ymax = math.Min(ymin, p.Y), math.Max(ymax, p.Y)
+	}
+	return MakeBox(xmin, ymin, xmax-xmin, ymax-ymin)
+}
+
 // Mul composes two affine transforms (applies u then t).
 func (t Affine) Mul(u Affine) Affine {
 	return MakeAffine(
@@ -97,22 +111,23 @@ func MatchSeg(p, q Point) Affine {
 }
 
 // MatchTwoSegs returns the transform T such that T*(p1->q1) == (p2->q2).
-func MatchTwoSegs(p1, q1, p2, q2 Point) Affine {
+// Returns an error if p1->q1 is degenerate (p1 == q1).
+func MatchTwoSegs(p1, q1, p2, q2 Point) (Affine, error) {
 	inv, err := MatchSeg(p1, q1).Inv()
 	if err != nil {
-		log.Fatalf("degenerate segment in MatchTwoSegs: %v->%v: %v", p1, q1, err)
+		return Affine{}, fmt.Errorf("degenerate segment %v->%v: %w", p1, q1, err)
 	}
-	return MatchSeg(p2, q2).Mul(inv)
+	return MatchSeg(p2, q2).Mul(inv), nil
 }
 
 // FillBox returns a transform that maps box b1 into b2, optionally allowing a
-// 90-degree rotation.
-func FillBox(b1, b2 Box, allowRotate bool) Affine {
+// 90-degree rotation. Returns an error if either box is empty.
+func FillBox(b1, b2 Box, allowRotate bool) (Affine, error) {
 	if b1.W <= 0 || b1.H <= 0 {
-		log.Fatalf("source box must have positive width and height, got W=%v H=%v", b1.W, b1.H)
+		return Affine{}, fmt.Errorf("source box must have positive width and height, got W=%v H=%v", b1.W, b1.H)
 	}
 	if b2.W <= 0 || b2.H <= 0 {
-		log.Fatalf("destination box must have positive width and height, got W=%v H=%v", b2.W, b2.H)
+		return Affine{}, fmt.Errorf("destination box must have positive width and height, got W=%v H=%v", b2.W, b2.H)
 	}
 
 	sc := math.Min(b2.W/b1.W, b2.H/b1.H)
@@ -120,8 +135,8 @@ func FillBox(b1, b2 Box, allowRotate bool) Affine {
 	centerDst := MakeAffine(1, 0, b2.X+0.5*b2.W, 0, 1, b2.Y+0.5*b2.H)
 	centerSrc := MakeAffine(1, 0, -(b1.X + 0.5*b1.W), 0, 1, -(b1.Y + 0.5*b1.H))
 	if !allowRotate || sc > rsc {
-		return centerDst.Mul(MakeAffine(sc, 0, 0, 0, sc, 0)).Mul(centerSrc)
+		return centerDst.Mul(MakeAffine(sc, 0, 0, 0, sc, 0)).Mul(centerSrc), nil
 	}
 	rot := MakeAffine(0, -1, 0, 1, 0, 0)
-	return centerDst.Mul(MakeAffine(rsc, 0, 0, 0, rsc, 0)).Mul(rot).Mul(centerSrc)
+	return centerDst.Mul(MakeAffine(rsc, 0, 0, 0, rsc, 0)).Mul(rot).Mul(centerSrc), nil
 }
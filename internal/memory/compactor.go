@@ -194,6 +194,7 @@ func (c *Compactor) copySlotData(
 	alloc.batch = targetBatch
 	alloc.slotIndex = targetSlotIdx
 	alloc.vertexCount = sourceSlot.vertexCount
+	targetBatch.uploadTransform(targetSlotIdx, alloc.transform) // transforms move with their slot
 
 	// Mark source slot as inactive.
 	sourceSlot.active = false
@@ -2,6 +2,13 @@
 //
 // The memory controller uses size-bucketed slot allocation to batch multiple clusters
 // into shared VBOs, minimizing draw calls while supporting fast per-cluster updates.
+//
+// Each slot also has an affine transform (from cluster-local to world space),
+// kept in a per-batch buffer texture alongside the VBO. The vertex shader
+// finds a vertex's slot through gl_VertexID (slots have fixed vertex offsets),
+// and applies its transform, so clusters can be moved, rotated or scaled
+// without re-uploading their vertices. Slots can also be drawn repeatedly, side
+// by side (see Repeat), for periodic clusters.
 package memory
 
 import (
@@ -13,6 +20,8 @@ import (
 	"time"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"github.com/irfansharif/zellij/internal/geom"
 )
 
 // TODO(irfanshari): Tune bucket sizes.
@@ -64,8 +73,16 @@ const (
 	slotsPerBatchL   = 64
 	slotsPerBatchXL  = 16
 	slotsPerBatchXXL = 1
+
+	// Per-slot transforms are stored as two RGBA32F texels: (a, b, c, 0) and
+	// (d, e, f, 0).
+	texelsPerTransform = 2
+	floatsPerTransform = texelsPerTransform * 4
 )
 
+// identityTransform is the transform slots start out with.
+var identityTransform = geom.MakeAffine(1, 0, 0, 0, 1, 0)
+
 // BucketSize represents different size categories for cluster geometry.
 type BucketSize int
 
@@ -101,12 +118,16 @@ type ClusterID int
 
 // MemoryController manages GPU memory for all clusters.
 type MemoryController struct {
-	buckets                 map[BucketSize]*BucketPool
-	clusterSlots            map[ClusterID]*SlotAllocation
-	stats                   Stats
-	compactor               *Compactor
-	clustersNeedingReupload map[ClusterID]bool
-	nextBatchID             int
+	buckets      map[BucketSize]*BucketPool
+	clusterSlots map[ClusterID]*SlotAllocation
+	stats        Stats
+	compactor    *Compactor
+	nextBatchID  int
+
+	// Shader uniform locations for per-slot transforms, set through
+	// SetTransformUniforms, and for repeats, set through SetRepeatUniforms.
+	uSlotCapacity, uSlotTransforms int32
+	uPeriods, uRepeat              int32
 }
 
 // Stats tracks performance metrics for the memory controller.
@@ -149,11 +170,15 @@ type Slot struct {
 	vertexOffset int
 }
 
-// Batch represents a VBO+VAO containing multiple fixed-capacity slots.
+// Batch represents a VBO+VAO containing multiple fixed-capacity slots, and a
+// buffer texture holding each slot's transform.
 type Batch struct {
 	id                  int
 	vbo                 uint32
 	vao                 uint32
+	tbo                 uint32 // transform buffer
+	tboTexture          uint32 // buffer texture over tbo
+	slotCapacity        int    // vertex capacity per slot (slot i starts at vertex i*slotCapacity)
 	totalVertexCapacity int
 	slots               []Slot
 	activeSlots         []int // indices of active slots in the slots array
@@ -177,11 +202,24 @@ type SlotRef struct {
 	slotIndex int
 }
 
-// SlotAllocation records where a cluster's data is stored.
+// SlotAllocation records where a cluster's data is stored, and the transform
+// applied to it.
 type SlotAllocation struct {
 	batch       *Batch
 	slotIndex   int
 	vertexCount int
+	transform   geom.Affine
+	repeat      *Repeat // nil if drawn once
+}
+
+// Repeat describes copies of a cluster's vertices drawn side by side, as for
+// periodic clusters: copy (i, j), for I <= i < I+Cols and J <= j < J+Rows, is
+// offset by i*Periods[0] + j*Periods[1] in cluster-local space (before the
+// cluster's transform).
+type Repeat struct {
+	Periods    [2]geom.Point
+	I, J       int
+	Cols, Rows int
 }
 
 // selectBucket chooses the smallest bucket that can fit the given vertex count.
@@ -359,6 +397,12 @@ func (mc *MemoryController) createBatch(bucket BucketSize, vertexCount int) (*Ba
 	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 	gl.BindVertexArray(0)
 
+	// Allocate the transform buffer, one transform per slot.
+	var tbo, tboTexture uint32
+	gl.GenBuffers(1, &tbo)
+	gl.GenTextures(1, &tboTexture)
+	allocateTransformBuffer(tbo, tboTexture, numSlots)
+
 	// Initialize slots array.
 	slots := make([]Slot, numSlots)
 	if bucket == BucketXXL {
@@ -370,10 +414,17 @@ func (mc *MemoryController) createBatch(bucket BucketSize, vertexCount int) (*Ba
 		}
 	}
 
+	slotCapacity := pool.vertexCapacityPerSlot
+	if bucket == BucketXXL {
+		slotCapacity = totalVertexCapacity
+	}
 	batch := &Batch{
 		id:                  mc.nextBatchID,
 		vbo:                 vbo,
 		vao:                 vao,
+		tbo:                 tbo,
+		tboTexture:          tboTexture,
+		slotCapacity:        slotCapacity,
 		totalVertexCapacity: totalVertexCapacity,
 		slots:               slots,
 		activeSlots:         make([]int, 0),
@@ -388,6 +439,30 @@ func (mc *MemoryController) createBatch(bucket BucketSize, vertexCount int) (*Ba
 	return batch, nil
 }
 
+// allocateTransformBuffer (re)allocates the transform buffer for the given
+// number of slots, and (re)attaches it to its buffer texture. Contents are
+// undefined until written.
+func allocateTransformBuffer(tbo, tboTexture uint32, numSlots int) {
+	gl.BindBuffer(gl.TEXTURE_BUFFER, tbo)
+	gl.BufferData(gl.TEXTURE_BUFFER, numSlots*floatsPerTransform*4, nil, gl.DYNAMIC_DRAW)
+	gl.BindBuffer(gl.TEXTURE_BUFFER, 0)
+
+	gl.BindTexture(gl.TEXTURE_BUFFER, tboTexture)
+	gl.TexBuffer(gl.TEXTURE_BUFFER, gl.RGBA32F, tbo)
+	gl.BindTexture(gl.TEXTURE_BUFFER, 0)
+}
+
+// uploadTransform writes the transform for the given slot.
+func (b *Batch) uploadTransform(slotIndex int, t geom.Affine) {
+	data := [floatsPerTransform]float32{
+		float32(t.A), float32(t.B), float32(t.C), 0,
+		float32(t.D), float32(t.E), float32(t.F), 0,
+	}
+	gl.BindBuffer(gl.TEXTURE_BUFFER, b.tbo)
+	gl.BufferSubData(gl.TEXTURE_BUFFER, slotIndex*floatsPerTransform*4, floatsPerTransform*4, gl.Ptr(&data[0]))
+	gl.BindBuffer(gl.TEXTURE_BUFFER, 0)
+}
+
 // allocateSlotInBatch allocates a specific slot in a batch and returns the slot index.
 // Removes the allocated slot from the pool's free list if present.
 func (b *Batch) allocateSlotInBatch(pool *BucketPool, clusterID ClusterID, vertexCount int) (int, error) {
@@ -442,6 +517,14 @@ func (b *Batch) cleanup() {
 		gl.DeleteBuffers(1, &b.vbo)
 		b.vbo = 0
 	}
+	if b.tboTexture != 0 {
+		gl.DeleteTextures(1, &b.tboTexture)
+		b.tboTexture = 0
+	}
+	if b.tbo != 0 {
+		gl.DeleteBuffers(1, &b.tbo)
+		b.tbo = 0
+	}
 }
 
 // canGrow checks if a batch is eligible for growth.
@@ -465,18 +548,13 @@ func (b *Batch) canGrow() bool {
 }
 
 // growBatch doubles a batch's capacity by allocating a new VBO and copying data.
-func (mc *MemoryController) growBatch(batch *Batch) ([]ClusterID, error) {
+func (mc *MemoryController) growBatch(batch *Batch) error {
 	if !batch.canGrow() {
-		return nil, fmt.Errorf("batch cannot grow")
+		return fmt.Errorf("batch cannot grow")
 	}
 
 	startTime := time.Now()
 
-	affectedClusters := make([]ClusterID, 0, len(batch.activeSlots))
-	for _, slotIdx := range batch.activeSlots {
-		affectedClusters = append(affectedClusters, batch.slots[slotIdx].clusterID)
-	}
-
 	var savedVAO, savedVBO int32
 	gl.GetIntegerv(gl.VERTEX_ARRAY_BINDING, &savedVAO)
 	gl.GetIntegerv(gl.ARRAY_BUFFER_BINDING, &savedVBO)
@@ -484,12 +562,20 @@ func (mc *MemoryController) growBatch(batch *Batch) ([]ClusterID, error) {
 	newCapacity := batch.totalVertexCapacity * 2
 	newSlotCount := len(batch.slots) * 2
 
+	// CPU-side copy of the existing vertex data (see the compactor), so
+	// clusters in the batch don't need re-uploading.
+	oldSize := batch.totalVertexCapacity * 6 * 4
+	oldData := make([]float32, batch.totalVertexCapacity*6)
+	gl.BindBuffer(gl.ARRAY_BUFFER, batch.vbo)
+	gl.GetBufferSubData(gl.ARRAY_BUFFER, 0, oldSize, gl.Ptr(oldData))
+
 	var newVBO uint32
 	gl.GenBuffers(1, &newVBO)
 	gl.BindBuffer(gl.ARRAY_BUFFER, newVBO)
 
 	size := newCapacity * 6 * 4
 	gl.BufferData(gl.ARRAY_BUFFER, size, nil, gl.DYNAMIC_DRAW)
+	gl.BufferSubData(gl.ARRAY_BUFFER, 0, oldSize, gl.Ptr(oldData))
 
 	gl.BindVertexArray(batch.vao)
 	gl.BindBuffer(gl.ARRAY_BUFFER, newVBO)
@@ -523,6 +609,15 @@ func (mc *MemoryController) growBatch(batch *Batch) ([]ClusterID, error) {
 	batch.slots = make([]Slot, newSlotCount)
 	copy(batch.slots, oldSlots)
 
+	// Grow the transform buffer too. Transforms are kept on the CPU, so
+	// there's nothing to copy over, just re-upload them.
+	allocateTransformBuffer(batch.tbo, batch.tboTexture, newSlotCount)
+	for _, slotIdx := range batch.activeSlots {
+		if alloc, ok := mc.clusterSlots[batch.slots[slotIdx].clusterID]; ok {
+			batch.uploadTransform(slotIdx, alloc.transform)
+		}
+	}
+
 	pool := mc.buckets[batch.bucketSize]
 	vertexOffset := pool.vertexCapacityPerSlot * len(oldSlots)
 	for i := len(oldSlots); i < newSlotCount; i++ {
@@ -539,15 +634,14 @@ func (mc *MemoryController) growBatch(batch *Batch) ([]ClusterID, error) {
 
 	mc.stats.GrowthEvents++
 	mc.stats.LastGrowthTimeUs = float64(time.Since(startTime).Microseconds())
-	return affectedClusters, nil
+	return nil
 }
 
 // NewMemoryController creates a new memory controller with initialized buckets.
 func NewMemoryController() *MemoryController {
 	mc := &MemoryController{
-		buckets:                 make(map[BucketSize]*BucketPool),
-		clusterSlots:            make(map[ClusterID]*SlotAllocation),
-		clustersNeedingReupload: make(map[ClusterID]bool),
+		buckets:      make(map[BucketSize]*BucketPool),
+		clusterSlots: make(map[ClusterID]*SlotAllocation),
 		stats: Stats{
 			BucketSizeStats: make(map[BucketSize]BucketSizeStats),
 		},
@@ -576,7 +670,11 @@ func (mc *MemoryController) EnsureSlot(clusterID ClusterID, vertices []float32)
 	vertexCount := len(vertices) / 6
 	bucketSize := selectBucket(vertexCount)
 
+	transform := identityTransform
+	var repeat *Repeat
 	if existing, exists := mc.clusterSlots[clusterID]; exists {
+		transform = existing.transform // carried over if we reallocate
+		repeat = existing.repeat
 		existingBucketSize := existing.batch.bucketSize
 
 		if existingBucketSize == BucketXXL {
@@ -634,10 +732,8 @@ func (mc *MemoryController) EnsureSlot(clusterID ClusterID, vertices []float32)
 			if GrowthEnableDynamic && bucketSize != BucketXXL {
 				for _, b := range pool.batches {
 					if b.canGrow() {
-						affectedClusters, err := mc.growBatch(b)
-						if err == nil {
+						if err := mc.growBatch(b); err == nil {
 							batch = b
-							mc.markClustersForReupload(affectedClusters)
 							break
 						}
 					}
@@ -674,15 +770,72 @@ slot_selected:
 		return fmt.Errorf("failed to upload vertex data: %w", err)
 	}
 
+	batch.uploadTransform(slotIndex, transform)
+
 	mc.clusterSlots[clusterID] = &SlotAllocation{
 		batch:       batch,
 		slotIndex:   slotIndex,
 		vertexCount: vertexCount,
+		transform:   transform,
+		repeat:      repeat,
 	}
 
 	return nil
 }
 
+// SetTransform sets the transform applied to a cluster's vertices when drawn.
+func (mc *MemoryController) SetTransform(clusterID ClusterID, transform geom.Affine) error {
+	alloc, exists := mc.clusterSlots[clusterID]
+	if !exists {
+		return fmt.Errorf("cluster %d not found", clusterID)
+	}
+	if alloc.transform == transform {
+		return nil // nothing to do
+	}
+	alloc.transform = transform
+	alloc.batch.uploadTransform(alloc.slotIndex, transform)
+	return nil
+}
+
+// Transform returns the transform applied to a cluster's vertices when drawn.
+func (mc *MemoryController) Transform(clusterID ClusterID) (geom.Affine, bool) {
+	alloc, exists := mc.clusterSlots[clusterID]
+	if !exists {
+		return geom.Affine{}, false
+	}
+	return alloc.transform, true
+}
+
+// SetRepeat sets how a cluster's vertices are repeated when drawn, or, if
+// repeat is nil, that they're drawn once.
+func (mc *MemoryController) SetRepeat(clusterID ClusterID, repeat *Repeat) error {
+	alloc, exists := mc.clusterSlots[clusterID]
+	if !exists {
+		return fmt.Errorf("cluster %d not found", clusterID)
+	}
+	if repeat != nil && (repeat.Cols <= 0 || repeat.Rows <= 0) {
+		return fmt.Errorf("invalid repeat %dx%d for cluster %d", repeat.Cols, repeat.Rows, clusterID)
+	}
+	alloc.repeat = repeat
+	return nil
+}
+
+// SetRepeatUniforms sets the shader uniform locations used to offset repeated
+// copies of a slot's vertices: the two period vectors (vec4), and the first
+// copy along with the number of copies per row (ivec3).
+func (mc *MemoryController) SetRepeatUniforms(periods, repeat int32) {
+	mc.uPeriods = periods
+	mc.uRepeat = repeat
+}
+
+// SetTransformUniforms sets the shader uniform locations used to look up
+// per-slot transforms when drawing: the slot's vertex capacity (int), and the
+// transform buffer (samplerBuffer).
+func (mc *MemoryController) SetTransformUniforms(slotCapacity, slotTransforms int32) {
+	mc.uSlotCapacity = slotCapacity
+	mc.uSlotTransforms = slotTransforms
+}
+
 // updateSlotInPlace updates an existing slot's vertex data without reallocation.
 func (mc *MemoryController) updateSlotInPlace(alloc *SlotAllocation, vertices []float32, vertexCount int) error {
 	slot := &alloc.batch.slots[alloc.slotIndex]
@@ -721,6 +874,12 @@ func (mc *MemoryController) RemoveCluster(clusterID ClusterID) error {
 	return nil
 }
 
+// HasCluster returns whether the cluster has an allocated slot.
+func (mc *MemoryController) HasCluster(clusterID ClusterID) bool {
+	_, exists := mc.clusterSlots[clusterID]
+	return exists
+}
+
 // ValidateClusterIntegrity checks that all tracked clusters have valid batch
 // references.
 func (mc *MemoryController) ValidateClusterIntegrity() error {
@@ -769,12 +928,40 @@ func (mc *MemoryController) ValidateClusterIntegrity() error {
 	return nil
 }
 
-// Draw renders all active clusters using MultiDrawArrays.
+// Draw renders all active clusters using MultiDrawArrays. Repeated clusters
+// are drawn first, underneath everything else, with one instanced draw each.
 func (mc *MemoryController) Draw() error {
 	drawCalls := 0
 
 	buckets := []BucketSize{BucketS, BucketM, BucketL, BucketXL, BucketXXL}
 
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.Uniform1i(mc.uSlotTransforms, 0)
+	for _, bucketSize := range buckets {
+		for _, batch := range mc.buckets[bucketSize].batches {
+			for _, slotIdx := range batch.activeSlots {
+				slot := batch.slots[slotIdx]
+				r := mc.clusterSlots[slot.clusterID].repeat
+				if r == nil {
+					continue
+				}
+
+				gl.BindVertexArray(batch.vao)
+				gl.BindTexture(gl.TEXTURE_BUFFER, batch.tboTexture)
+				gl.Uniform1i(mc.uSlotCapacity, int32(batch.slotCapacity))
+				gl.Uniform4f(mc.uPeriods,
+					float32(r.Periods[0].X), float32(r.Periods[0].Y),
+					float32(r.Periods[1].X), float32(r.Periods[1].Y))
+				gl.Uniform3i(mc.uRepeat, int32(r.I), int32(r.J), int32(r.Cols))
+				gl.DrawArraysInstanced(gl.TRIANGLES, int32(slot.vertexOffset), int32(slot.vertexCount), int32(r.Cols*r.Rows))
+				drawCalls++
+			}
+		}
+	}
+
+	// Everything else is drawn once, as copy (0, 0).
+	gl.Uniform4f(mc.uPeriods, 0, 0, 0, 0)
+	gl.Uniform3i(mc.uRepeat, 0, 0, 1)
 	for _, bucketSize := range buckets {
 		pool := mc.buckets[bucketSize]
 
@@ -783,23 +970,32 @@ func (mc *MemoryController) Draw() error {
 				continue
 			}
 
-			gl.BindVertexArray(batch.vao)
+			firsts := make([]int32, 0, len(batch.activeSlots))
+			counts := make([]int32, 0, len(batch.activeSlots))
 
-			firsts := make([]int32, len(batch.activeSlots))
-			counts := make([]int32, len(batch.activeSlots))
-
-			for i, slotIdx := range batch.activeSlots {
+			for _, slotIdx := range batch.activeSlots {
 				slot := batch.slots[slotIdx]
-				firsts[i] = int32(slot.vertexOffset)
-				counts[i] = int32(slot.vertexCount)
+				if mc.clusterSlots[slot.clusterID].repeat != nil {
+					continue // drawn above
+				}
+				firsts = append(firsts, int32(slot.vertexOffset))
+				counts = append(counts, int32(slot.vertexCount))
+			}
+			if len(firsts) == 0 {
+				continue
 			}
 
+			gl.BindVertexArray(batch.vao)
+			gl.BindTexture(gl.TEXTURE_BUFFER, batch.tboTexture)
+			gl.Uniform1i(mc.uSlotCapacity, int32(batch.slotCapacity))
+
 			gl.MultiDrawArrays(gl.TRIANGLES, &firsts[0], &counts[0], int32(len(firsts)))
 			drawCalls++
 		}
 	}
 
 	gl.BindVertexArray(0)
+	gl.BindTexture(gl.TEXTURE_BUFFER, 0)
 	mc.stats.DrawCallsPerFrame = drawCalls
 	return nil
 }
@@ -976,28 +1172,6 @@ func formatNumber(n int64) string {
 	return fmt.Sprintf("%.1fM", float64(n)/1000000.0)
 }
 
-// markClustersForReupload marks clusters as needing re-upload after batch growth.
-func (mc *MemoryController) markClustersForReupload(clusterIDs []ClusterID) {
-	for _, id := range clusterIDs {
-		mc.clustersNeedingReupload[id] = true
-	}
-}
-
-// GetAndClearClustersNeedingReupload returns clusters affected by batch growth and clears the list.
-func (mc *MemoryController) GetAndClearClustersNeedingReupload() []ClusterID {
-	if len(mc.clustersNeedingReupload) == 0 {
-		return nil
-	}
-
-	result := make([]ClusterID, 0, len(mc.clustersNeedingReupload))
-	for clusterID := range mc.clustersNeedingReupload {
-		result = append(result, clusterID)
-	}
-
-	mc.clustersNeedingReupload = make(map[ClusterID]bool)
-	return result
-}
-
 // TryCompaction attempts to compact sparse batches if enabled. Should be called
 // periodically (e.g., every 60 frames). Limits compaction to DefragMaxPerFrame
 // batches per call.
@@ -0,0 +1,455 @@
+// Package mesh turns abstract tile compositions into decorated world-space
+// geometry. It:
+// 1. Maps tiles from unit grid coordinates to cluster-local coordinates
+// (centered at the origin), and from there to world (canvas) coordinates
+// through the cluster's transform (translation, rotation, scale).
+// 2. Aligns filler patterns onto each tile, producing coloured polygons.
+// 3. Triangulates polygons into the interleaved vertex stream the renderer
+// uploads.
+//
+// The renderer uploads cluster-local geometry and applies cluster transforms
+// on the GPU, so moving, rotating or scaling a cluster doesn't regenerate it.
+// Periodic clusters are built once, as their translation unit, and repeated
+// (see Copies). Everything here is also usable on the CPU, headless (for
+// exports).
+package mesh
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/irfansharif/zellij/internal/fillers"
+	"github.com/irfansharif/zellij/internal/gen"
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/palette"
+)
+
+// DefaultUnitSize is the default number of world units per grid unit.
+const DefaultUnitSize = 25.0
+
+// FloatsPerVertex is the number of float32s per vertex in the interleaved
+// vertex stream: x, y, r, g, b, a.
+const FloatsPerVertex = 6
+
+// Cluster holds what's needed to build geometry for a single cluster.
+type Cluster struct {
+	Composition gen.Composition
+	UnitSize    float64 // world units per grid unit (0 is treated as DefaultUnitSize)
+	CanvasPos   geom.Point
+	Palette     palette.Palette
+	Seed        int64   // seed for deterministic per-cluster effects (e.g., shimmer)
+	Rotation    float64 // radians, about the cluster's center
+	Scale       float64 // uniform scale about the cluster's center (0 is treated as 1)
+}
+
+// Polygon is a filled polygon in world space.
+type Polygon struct {
+	Colour color.RGBA
+	Path   []geom.Point
+}
+
+// Polygons generates the decorated polygons for a cluster in world/canvas
+// space. It also returns the number of tiles that had no matching filler
+// pattern (and were skipped, or filled with the fillers.CurrentFallback).
+func Polygons(c Cluster) (polys []Polygon, unfilled int, err error) {
+	polys, unfilled, err = LocalPolygons(c)
+	if err != nil {
+		return nil, 0, err
+	}
+	transform := Transform(c)
+	for i := range polys {
+		for j, p := range polys[i].Path {
+			polys[i].Path[j] = transform.MulPoint(p)
+		}
+	}
+	return polys, unfilled, nil
+}
+
+// LocalPolygons is like Polygons, but generates polygons in cluster-local
+// space, centered at the origin and without the cluster's transform applied.
+func LocalPolygons(c Cluster) (polys []Polygon, unfilled int, err error) {
+	modelToLocal, err := ModelToLocal(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Apply shimmer to the cluster's palette deterministically using the
+	// cluster seed.
+	localRand := rand.New(rand.NewSource(c.Seed))
+	shimmerPal := palette.Shimmered(c.Palette, c.Composition.Shimmer, localRand)
+
+	unitSize := c.UnitSize
+	if unitSize == 0 {
+		unitSize = DefaultUnitSize
+	}
+
+	for _, tile := range c.Composition.Tiles {
+		// Transform tile to local coordinates.
+		localPath := make([]geom.Point, len(tile.Path))
+		for i, p := range tile.Path {
+			localPath[i] = modelToLocal.MulPoint(p)
+		}
+
+		// Try to match filler pattern. Local space is centered on the
+		// composition, so the tile's centroid there is relative to it.
+		var centroid geom.Point
+		for _, p := range localPath {
+			centroid = centroid.Add(p)
+		}
+		t := fillers.Tile{Seed: c.Seed, Center: centroid.Scale(1 / (float64(len(localPath)) * unitSize))}
+		tilePolys, ok := fillTile(localPath, t, shimmerPal)
+		if !ok {
+			unfilled++
+		}
+		polys = append(polys, tilePolys...)
+	}
+	return polys, unfilled, nil
+}
+
+// ModelToWorld returns the transform from the composition's unit grid
+// coordinates to world/canvas space: the cluster's model bounds, sized in
+// world units and placed by the cluster's transform.
+func ModelToWorld(c Cluster) (geom.Affine, error) {
+	modelToLocal, err := ModelToLocal(c)
+	if err != nil {
+		return geom.Affine{}, err
+	}
+	return Transform(c).Mul(modelToLocal), nil
+}
+
+// ModelToLocal returns the transform from the composition's unit grid
+// coordinates to cluster-local space: the cluster's model bounds, sized in
+// world units (UnitSize per grid unit) and centered at the origin. It doesn't
+// depend on the viewport, so clusters are the same size however they're
+// viewed or exported.
+func ModelToLocal(c Cluster) (geom.Affine, error) {
+	bounds, err := ModelBounds(c.Composition)
+	if err != nil {
+		return geom.Affine{}, err
+	}
+
+	unitSize := c.UnitSize
+	if unitSize == 0 {
+		unitSize = DefaultUnitSize
+	}
+	worldW := bounds.W * unitSize
+	worldH := bounds.H * unitSize
+
+	// Model bounds → sized bounds centered at the origin.
+	localBounds := geom.MakeBox(-0.5*worldW, -0.5*worldH, worldW, worldH)
+	return geom.FillBox(bounds, localBounds, false)
+}
+
+// Transform returns the cluster's transform, from cluster-local to
+// world/canvas space: scale and rotation about the cluster's center, followed
+// by a translation to its canvas position.
+func Transform(c Cluster) geom.Affine {
+	scale := c.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	sin, cos := math.Sincos(c.Rotation)
+	return geom.MakeAffine(
+		scale*cos, -scale*sin, c.CanvasPos.X,
+		scale*sin, scale*cos, c.CanvasPos.Y,
+	)
+}
+
+// WorldBounds returns the cluster's axis-aligned bounds in world/canvas space
+// (see ModelToWorld). For periodic clusters, these are the bounds of copy
+// (0, 0) of the translation unit.
+func WorldBounds(c Cluster) (geom.Box, error) {
+	bounds, err := ModelBounds(c.Composition)
+	if err != nil {
+		return geom.Box{}, err
+	}
+	modelToWorld, err := ModelToWorld(c)
+	if err != nil {
+		return geom.Box{}, err
+	}
+	return modelToWorld.MulBox(bounds), nil
+}
+
+// maxCopies bounds the number of copies of a translation unit PolygonsIn
+// generates.
+const maxCopies = 1 << 16
+
+// Copies is a range of copies of a periodic cluster's translation unit: copy
+// (i, j), for I <= i < I+Cols and J <= j < J+Rows, is offset by i and j times
+// the first and second period (see LocalPeriods) in cluster-local space.
+type Copies struct {
+	I, J       int
+	Cols, Rows int
+}
+
+// Len returns the number of copies in the range.
+func (cs Copies) Len() int { return cs.Cols * cs.Rows }
+
+// LocalPeriods returns the vectors a periodic cluster's translation unit
+// repeats along in cluster-local space (see gen.Composition.Period), zero for
+// clusters that aren't periodic.
+func LocalPeriods(c Cluster) ([2]geom.Point, error) {
+	if !c.Composition.Periodic {
+		return [2]geom.Point{}, nil
+	}
+	modelToLocal, err := ModelToLocal(c)
+	if err != nil {
+		return [2]geom.Point{}, err
+	}
+	origin := modelToLocal.MulPoint(geom.Point{})
+	var periods [2]geom.Point
+	for i, period := range c.Composition.Period {
+		periods[i] = modelToLocal.MulPoint(period).Sub(origin)
+	}
+	return periods, nil
+}
+
+// CopiesIn returns the copies of a periodic cluster's translation unit needed
+// to cover the given region of cluster-local space (possibly a few more).
+// Clusters that aren't periodic only have copy (0, 0).
+func CopiesIn(c Cluster, region geom.Box) (Copies, error) {
+	if !c.Composition.Periodic {
+		return Copies{Cols: 1, Rows: 1}, nil
+	}
+	bounds, err := ModelBounds(c.Composition)
+	if err != nil {
+		return Copies{}, err
+	}
+	modelToLocal, err := ModelToLocal(c)
+	if err != nil {
+		return Copies{}, err
+	}
+	unit := modelToLocal.MulBox(bounds)
+	periods, err := LocalPeriods(c)
+	if err != nil {
+		return Copies{}, err
+	}
+	toCopies, err := geom.MakeAffine(periods[0].X, periods[1].X, 0, periods[0].Y, periods[1].Y, 0).Inv()
+	if err != nil {
+		return Copies{}, fmt.Errorf("degenerate periods %v: %w", periods, err)
+	}
+
+	// Copy (i, j) overlaps the region if its offset is within the region
+	// less the unit's bounds.
+	offsets := geom.MakeBox(region.X-unit.X-unit.W, region.Y-unit.Y-unit.H, region.W+unit.W, region.H+unit.H)
+	ij := toCopies.MulBox(offsets)
+	i0, j0 := int(math.Floor(ij.X)), int(math.Floor(ij.Y))
+	i1, j1 := int(math.Ceil(ij.X+ij.W)), int(math.Ceil(ij.Y+ij.H))
+	return Copies{I: i0, J: j0, Cols: i1 - i0 + 1, Rows: j1 - j0 + 1}, nil
+}
+
+// PolygonsIn is like Polygons, but repeats periodic clusters' translation unit
+// to cover the given region of world/canvas space. Polygons entirely outside
+// the region are left out.
+func PolygonsIn(c Cluster, region geom.Box) (polys []Polygon, unfilled int, err error) {
+	if !c.Composition.Periodic {
+		return Polygons(c)
+	}
+	local, unfilled, err := LocalPolygons(c)
+	if err != nil {
+		return nil, 0, err
+	}
+	transform := Transform(c)
+	toLocal, err := transform.Inv()
+	if err != nil {
+		return nil, 0, err
+	}
+	copies, err := CopiesIn(c, toLocal.MulBox(region))
+	if err != nil {
+		return nil, 0, err
+	}
+	if copies.Len() > maxCopies {
+		return nil, 0, fmt.Errorf("too many copies (%d) of the translation unit to cover %v", copies.Len(), region)
+	}
+	periods, err := LocalPeriods(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := copies.I; i < copies.I+copies.Cols; i++ {
+		for j := copies.J; j < copies.J+copies.Rows; j++ {
+			offset := periods[0].Scale(float64(i)).Add(periods[1].Scale(float64(j)))
+			for _, poly := range local {
+				path := make([]geom.Point, len(poly.Path))
+				for k, p := range poly.Path {
+					path[k] = transform.MulPoint(p.Add(offset))
+				}
+				if b, ok := Bounds([]Polygon{{Path: path}}); ok && overlaps(b, region) {
+					polys = append(polys, Polygon{Colour: poly.Colour, Path: path})
+				}
+			}
+		}
+	}
+	return polys, unfilled, nil
+}
+
+// overlaps returns whether the two boxes overlap.
+func overlaps(a, b geom.Box) bool {
+	return a.X <= b.X+b.W && b.X <= a.X+a.W && a.Y <= b.Y+b.H && b.Y <= a.Y+a.H
+}
+
+// ViewTransform returns the transform from world coordinates to screen
+// (pixel) coordinates for a w×h viewport: zoom scaling around the viewport
+// center, followed by a pan translation in screen space.
+func ViewTransform(w, h int, zoom, panX, panY float64) geom.Affine {
+	viewportCenterX := float64(w) / 2.0
+	viewportCenterY := float64(h) / 2.0
+
+	translateToOrigin := geom.MakeAffine(1, 0, -viewportCenterX, 0, 1, -viewportCenterY)
+	uniformScale := geom.MakeAffine(zoom, 0, 0, 0, zoom, 0)
+	translateBack := geom.MakeAffine(1, 0, viewportCenterX, 0, 1, viewportCenterY)
+	panTranslation := geom.MakeAffine(1, 0, panX, 0, 1, panY)
+
+	return panTranslation.Mul(translateBack.Mul(uniformScale.Mul(translateToOrigin)))
+}
+
+// debugColour is the colour of shapes in fillers.DebugColour.
+var debugColour = color.RGBA{R: 255, G: 0, B: 255, A: 255}
+
+// fillTile aligns the matching filler pattern onto a (world-space) tile and
+// returns its coloured shapes. Returns false if no pattern matches, along
+// with the shapes of the fallback pattern, if any (see fillers.Fallback).
+func fillTile(tilePath []geom.Point, t fillers.Tile, pal palette.Palette) ([]Polygon, bool) {
+	pattern, alignment, found := fillers.Match(tilePath, t)
+	if !found {
+		fallback := fillers.CurrentFallback()
+		if fallback == nil {
+			return nil, false
+		}
+		pattern, alignment = fallback(tilePath), geom.MakeAffine(1, 0, 0, 0, 1, 0)
+	}
+
+	polys := make([]Polygon, 0, len(pattern.Shapes))
+	for _, shape := range pattern.Shapes {
+		if len(shape.Path) < 3 {
+			continue
+		}
+
+		// Get shape color.
+		colour := pal[minInt(4, maxInt(0, shape.Colour))]
+		if shape.Colour == fillers.DebugColour {
+			colour = debugColour
+		}
+
+		// Transform shape vertices to tile space.
+		path := make([]geom.Point, len(shape.Path))
+		for j, vertex := range shape.Path {
+			path[j] = alignment.MulPoint(vertex)
+		}
+		polys = append(polys, Polygon{Colour: colour, Path: path})
+	}
+	return polys, found
+}
+
+// Vertices triangulates the given polygons into the interleaved
+// [x,y,r,g,b,a] float32 stream (array-based: no deduplication).
+func Vertices(polys []Polygon) []float32 {
+	vertices := make([]float32, 0, len(polys)*3*3*FloatsPerVertex) // estimate
+	for _, poly := range polys {
+		triangles := earClip(poly.Path)
+		if triangles == nil {
+			continue
+		}
+
+		c := poly.Colour
+		for _, tri := range triangles {
+			for v := 0; v < 3; v++ {
+				vertices = append(vertices,
+					float32(tri[v].X), float32(tri[v].Y), // position
+					float32(c.R)/255.0, float32(c.G)/255.0,
+					float32(c.B)/255.0, float32(c.A)/255.0, // color
+				)
+			}
+		}
+	}
+	return vertices
+}
+
+// Bounds returns the axis-aligned bounding box of the given polygons. Returns
+// false if there's nothing to bound.
+func Bounds(polys []Polygon) (geom.Box, bool) {
+	xmin, xmax := math.MaxFloat64, -math.MaxFloat64
+	ymin, ymax := math.MaxFloat64, -math.MaxFloat64
+	for _, poly := range polys {
+		for _, p := range poly.Path {
+			xmin = math.Min(xmin, p.X)
+			xmax = math.Max(xmax, p.X)
+			ymin = math.Min(ymin, p.Y)
+			ymax = math.Max(ymax, p.Y)
+		}
+	}
+	if xmin > xmax || ymin > ymax {
+		return geom.Box{}, false
+	}
+	return geom.MakeBox(xmin, ymin, xmax-xmin, ymax-ymin), true
+}
+
+// ModelBounds calculates the bounding box for the composition's geometry.
+//
+// Returns the axis-aligned bounding box containing all tiles or boundary points.
+// If boundary is available, uses it for more accurate bounds. Falls back to
+// tile-based bounds if boundary is unavailable.
+//
+// Returns error if no valid geometry is found.
+func ModelBounds(comp gen.Composition) (geom.Box, error) {
+	xmin, xmax := math.MaxFloat64, -math.MaxFloat64
+	ymin, ymax := math.MaxFloat64, -math.MaxFloat64
+	pointCount := 0
+
+	// Try boundary first for more accurate bounds.
+	if len(comp.Boundary) > 0 {
+		for _, p := range comp.Boundary {
+			xmin = math.Min(xmin, p.X)
+			xmax = math.Max(xmax, p.X)
+			ymin = math.Min(ymin, p.Y)
+			ymax = math.Max(ymax, p.Y)
+			pointCount++
+		}
+
+	} else {
+		// Fallback to tile geometry
+		for _, tile := range comp.Tiles {
+			for _, p := range tile.Path {
+				xmin = math.Min(xmin, p.X)
+				xmax = math.Max(xmax, p.X)
+				ymin = math.Min(ymin, p.Y)
+				ymax = math.Max(ymax, p.Y)
+				pointCount++
+			}
+		}
+
+	}
+
+	// Validate computed bounds.
+	if pointCount == 0 {
+		return geom.Box{}, fmt.Errorf("no valid geometry points found in composition")
+	}
+	if math.IsInf(xmin, 0) || math.IsInf(xmax, 0) || math.IsInf(ymin, 0) || math.IsInf(ymax, 0) {
+		return geom.Box{}, fmt.Errorf("computed bounds contain infinite values: x[%f,%f] y[%f,%f]", xmin, xmax, ymin, ymax)
+	}
+	if xmin >= xmax || ymin >= ymax {
+		return geom.Box{}, fmt.Errorf("computed bounds are degenerate: x[%f,%f] y[%f,%f]", xmin, xmax, ymin, ymax)
+	}
+
+	width := xmax - xmin
+	height := ymax - ymin
+	return geom.MakeBox(xmin, ymin, width, height), nil
+}
+
+// minInt returns the minimum of two integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the maximum of two integers
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,267 @@
+// Package raster implements a pure-Go software rasterizer for the interleaved
+// [x,y,r,g,b,a] vertex stream the mesh package produces (the same stream the
+// GPU renders). It needs no GPU or OpenGL context, which makes it usable for
+// thumbnails, tests and batch output.
+//
+// Antialiasing is done through supersampling: each pixel is covered by an S×S
+// grid of samples, rasterized with a top-left fill rule (so triangles sharing
+// an edge never double-cover a sample), then box-filtered down. To bound
+// memory for large outputs, the image is processed in horizontal strips, with
+// triangles binned by the strips they overlap.
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/mesh"
+)
+
+const (
+	// DefaultSamples is the default number of samples per pixel, per axis.
+	DefaultSamples = 4
+
+	stripHeight = 32 // rows per strip
+)
+
+// triangle is a screen-space triangle with a straight (non-premultiplied)
+// colour.
+type triangle struct {
+	p          [3]geom.Point
+	r, g, b, a float32
+	ymin, ymax float64
+}
+
+// Rasterize draws the vertex stream onto img, transforming vertices from world
+// to image pixel coordinates with the given transform (see mesh.ViewTransform).
+// Triangles are composited in order using "over" blending onto img's existing
+// contents. samples is the per-axis supersampling factor (<= 0 uses
+// DefaultSamples).
+func Rasterize(img *image.RGBA, vertices []float32, transform geom.Affine, samples int) error {
+	if len(vertices)%(3*mesh.FloatsPerVertex) != 0 {
+		return fmt.Errorf("vertex data must be whole triangles of %d floats per vertex, got %d floats",
+			mesh.FloatsPerVertex, len(vertices))
+	}
+	if samples <= 0 {
+		samples = DefaultSamples
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil
+	}
+
+	// Transform triangles into screen space and bin them by strip.
+	numStrips := (h + stripHeight - 1) / stripHeight
+	bins := make([][]int, numStrips)
+	tris := make([]triangle, 0, len(vertices)/(3*mesh.FloatsPerVertex))
+	for i := 0; i < len(vertices); i += 3 * mesh.FloatsPerVertex {
+		var t triangle
+		for v := 0; v < 3; v++ {
+			off := i + v*mesh.FloatsPerVertex
+			t.p[v] = transform.MulPoint(geom.MakePoint(float64(vertices[off]), float64(vertices[off+1])))
+		}
+		off := i + 2*mesh.FloatsPerVertex // flat shading, colour from the last vertex
+		t.r, t.g, t.b, t.a = vertices[off+2], vertices[off+3], vertices[off+4], vertices[off+5]
+		if t.a <= 0 {
+			continue
+		}
+
+		t.ymin = math.Min(t.p[0].Y, math.Min(t.p[1].Y, t.p[2].Y))
+		t.ymax = math.Max(t.p[0].Y, math.Max(t.p[1].Y, t.p[2].Y))
+		xmin := math.Min(t.p[0].X, math.Min(t.p[1].X, t.p[2].X))
+		xmax := math.Max(t.p[0].X, math.Max(t.p[1].X, t.p[2].X))
+		if t.ymax < 0 || t.ymin >= float64(h) || xmax < 0 || xmin >= float64(w) {
+			continue // entirely off-image
+		}
+
+		idx := len(tris)
+		tris = append(tris, t)
+		first := clampInt(int(math.Floor(t.ymin))/stripHeight, 0, numStrips-1)
+		last := clampInt(int(math.Floor(t.ymax))/stripHeight, 0, numStrips-1)
+		for s := first; s <= last; s++ {
+			bins[s] = append(bins[s], idx)
+		}
+	}
+
+	// Supersampled buffer for a single strip: straight RGBA floats.
+	sw := w * samples
+	buf := make([]float32, sw*stripHeight*samples*4)
+	for s := 0; s < numStrips; s++ {
+		y0 := s * stripHeight
+		rows := min(stripHeight, h-y0)
+		sh := rows * samples
+		st := strip{buf: buf[:sw*sh*4], sw: sw, sh: sh, samples: samples, y0: y0}
+
+		st.load(img, bounds.Min, w)
+		for _, idx := range bins[s] {
+			st.fill(&tris[idx])
+		}
+		st.resolve(img, bounds.Min, w)
+	}
+	return nil
+}
+
+// strip is the supersampled working buffer for a horizontal band of the image.
+type strip struct {
+	buf     []float32
+	sw, sh  int // size in samples
+	samples int // per axis
+	y0      int // first image row
+}
+
+// load initializes every sample from the image's current pixel.
+func (st *strip) load(img *image.RGBA, origin image.Point, w int) {
+	rows := st.sh / st.samples
+	for py := 0; py < rows; py++ {
+		for px := 0; px < w; px++ {
+			c := img.RGBAAt(origin.X+px, origin.Y+st.y0+py)
+			r, g, b, a := unpremultiply(c)
+			for sy := 0; sy < st.samples; sy++ {
+				row := (py*st.samples + sy) * st.sw
+				for sx := 0; sx < st.samples; sx++ {
+					i := (row + px*st.samples + sx) * 4
+					st.buf[i], st.buf[i+1], st.buf[i+2], st.buf[i+3] = r, g, b, a
+				}
+			}
+		}
+	}
+}
+
+// fill rasterizes a single triangle into the strip's samples.
+func (st *strip) fill(t *triangle) {
+	s := float64(st.samples)
+	// Work in sample space, relative to the strip.
+	var p [3]geom.Point
+	for i := range t.p {
+		p[i] = geom.MakePoint(t.p[i].X*s, (t.p[i].Y-float64(st.y0))*s)
+	}
+
+	area := edge(p[0], p[1], p[2])
+	if area == 0 {
+		return // degenerate
+	}
+	if area < 0 {
+		// Orient consistently so that interior samples have positive edge
+		// functions.
+		p[1], p[2] = p[2], p[1]
+	}
+
+	// Sample centers lie at (i+0.5, j+0.5).
+	xmin := clampInt(int(math.Ceil(math.Min(p[0].X, math.Min(p[1].X, p[2].X))-0.5)), 0, st.sw-1)
+	xmax := clampInt(int(math.Floor(math.Max(p[0].X, math.Max(p[1].X, p[2].X))-0.5)), 0, st.sw-1)
+	ymin := clampInt(int(math.Ceil(math.Min(p[0].Y, math.Min(p[1].Y, p[2].Y))-0.5)), 0, st.sh-1)
+	ymax := clampInt(int(math.Floor(math.Max(p[0].Y, math.Max(p[1].Y, p[2].Y))-0.5)), 0, st.sh-1)
+
+	topLeft := [3]bool{
+		isTopLeft(p[1], p[2]),
+		isTopLeft(p[2], p[0]),
+		isTopLeft(p[0], p[1]),
+	}
+	alpha := t.a
+	for y := ymin; y <= ymax; y++ {
+		row := y * st.sw
+		for x := xmin; x <= xmax; x++ {
+			q := geom.MakePoint(float64(x)+0.5, float64(y)+0.5)
+			w0 := edge(p[1], p[2], q)
+			w1 := edge(p[2], p[0], q)
+			w2 := edge(p[0], p[1], q)
+			if !inside(w0, topLeft[0]) || !inside(w1, topLeft[1]) || !inside(w2, topLeft[2]) {
+				continue
+			}
+
+			i := (row + x) * 4
+			if alpha >= 1 {
+				st.buf[i], st.buf[i+1], st.buf[i+2], st.buf[i+3] = t.r, t.g, t.b, 1
+				continue
+			}
+			// Straight-alpha "over" compositing.
+			da := st.buf[i+3]
+			oa := alpha + da*(1-alpha)
+			if oa <= 0 {
+				continue
+			}
+			st.buf[i] = (t.r*alpha + st.buf[i]*da*(1-alpha)) / oa
+			st.buf[i+1] = (t.g*alpha + st.buf[i+1]*da*(1-alpha)) / oa
+			st.buf[i+2] = (t.b*alpha + st.buf[i+2]*da*(1-alpha)) / oa
+			st.buf[i+3] = oa
+		}
+	}
+}
+
+// resolve box-filters the strip's samples back into the image.
+func (st *strip) resolve(img *image.RGBA, origin image.Point, w int) {
+	rows := st.sh / st.samples
+	n := float32(st.samples * st.samples)
+	for py := 0; py < rows; py++ {
+		for px := 0; px < w; px++ {
+			// Average premultiplied values, so transparent samples don't
+			// bleed their colour.
+			var r, g, b, a float32
+			for sy := 0; sy < st.samples; sy++ {
+				row := (py*st.samples + sy) * st.sw
+				for sx := 0; sx < st.samples; sx++ {
+					i := (row + px*st.samples + sx) * 4
+					sa := st.buf[i+3]
+					r += st.buf[i] * sa
+					g += st.buf[i+1] * sa
+					b += st.buf[i+2] * sa
+					a += sa
+				}
+			}
+			img.SetRGBA(origin.X+px, origin.Y+st.y0+py, color.RGBA{
+				R: toByte(r / n), G: toByte(g / n), B: toByte(b / n), A: toByte(a / n),
+			})
+		}
+	}
+}
+
+// edge returns the (doubled, signed) area of triangle abc; positive when c
+// lies to the right of a→b as seen on screen (y pointing down).
+func edge(a, b, c geom.Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// isTopLeft reports whether the edge a→b (of a positively oriented triangle)
+// is a top or left edge, which own the samples lying exactly on them.
+func isTopLeft(a, b geom.Point) bool {
+	d := b.Sub(a)
+	return (d.Y == 0 && d.X > 0) || d.Y < 0
+}
+
+func inside(w float64, topLeft bool) bool {
+	return w > 0 || (w == 0 && topLeft)
+}
+
+// unpremultiply converts an image colour into straight-alpha floats.
+func unpremultiply(c color.RGBA) (r, g, b, a float32) {
+	if c.A == 0 {
+		return 0, 0, 0, 0
+	}
+	a = float32(c.A) / 255
+	return float32(c.R) / 255 / a, float32(c.G) / 255 / a, float32(c.B) / 255 / a, a
+}
+
+func toByte(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 255
+	}
+	return uint8(v*255 + 0.5)
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
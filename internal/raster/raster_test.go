@@ -0,0 +1,93 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/irfansharif/zellij/internal/geom"
+	"github.com/irfansharif/zellij/internal/mesh"
+)
+
+var identity = geom.MakeAffine(1, 0, 0, 0, 1, 0)
+
+// triangles returns the vertex stream of the given triangles' corners, all
+// in the given colour.
+func triangles(c [4]float32, corners ...geom.Point) []float32 {
+	var vertices []float32
+	for _, p := range corners {
+		vertices = append(vertices, float32(p.X), float32(p.Y), c[0], c[1], c[2], c[3])
+	}
+	return vertices
+}
+
+func TestRasterizeTriangle(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	red := [4]float32{1, 0, 0, 1}
+	vertices := triangles(red, geom.MakePoint(8, 8), geom.MakePoint(56, 8), geom.MakePoint(8, 56))
+	if err := Rasterize(img, vertices, identity, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{x: 8, y: 8, want: color.RGBA{R: 255, A: 255}},   // the corner, fully in
+		{x: 20, y: 20, want: color.RGBA{R: 255, A: 255}}, // inside
+		{x: 30, y: 7, want: color.RGBA{}},                // just above
+		{x: 7, y: 30, want: color.RGBA{}},                // just left
+		{x: 40, y: 40, want: color.RGBA{}},               // past the long edge
+	} {
+		if got := img.RGBAAt(tc.x, tc.y); got != tc.want {
+			t.Errorf("pixel %d,%d = %v, want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+	// Pixels the long edge runs through diagonally are half covered.
+	if got := img.RGBAAt(30, 33); got.A == 0 || got.A == 255 {
+		t.Errorf("pixel 30,33 on the long edge = %v, want partly covered", got)
+	}
+}
+
+func TestRasterizeSharedEdges(t *testing.T) {
+	// A square split into four triangles about its center, in translucent
+	// grey: blended once everywhere, on the edges they share too.
+	grey := [4]float32{0.5, 0.5, 0.5, 0.5}
+	a, b, c, d := geom.MakePoint(0, 0), geom.MakePoint(32, 0), geom.MakePoint(32, 32), geom.MakePoint(0, 32)
+	center := geom.MakePoint(16, 16)
+	vertices := triangles(grey, a, b, center, b, c, center, c, d, center, d, a, center)
+
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for i := range img.Pix {
+		img.Pix[i] = 255 // opaque white
+	}
+	if err := Rasterize(img, vertices, identity, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := img.RGBAAt(16, 4)
+	if want.A != 255 || want.R == 255 {
+		t.Fatalf("pixel 16,4 = %v, want grey over white", want)
+	}
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if got := img.RGBAAt(x, y); got != want {
+				t.Fatalf("pixel %d,%d = %v, want %v, as everywhere else", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRasterizeWholeTriangles(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	red := [4]float32{1, 0, 0, 1}
+	vertices := triangles(red, geom.MakePoint(0, 0), geom.MakePoint(8, 0), geom.MakePoint(0, 8), geom.MakePoint(8, 8))
+	if err := Rasterize(img, vertices, identity, 0); err == nil {
+		t.Errorf("Rasterize() of %d vertices = nil error", len(vertices)/mesh.FloatsPerVertex)
+	}
+	if err := Rasterize(img, vertices[:len(vertices)-1], identity, 0); err == nil {
+		t.Errorf("Rasterize() of %d floats = nil error", len(vertices)-1)
+	}
+	if got := img.RGBAAt(1, 1); got != (color.RGBA{}) {
+		t.Errorf("pixel 1,1 = %v after rejected vertices, want it untouched", got)
+	}
+}
@@ -1,25 +1,41 @@
 // Package render handles the visual presentation of generated Zellij patterns.
 //
 // It takes abstract tile compositions from the gen package and:
-// 1. Maps tiles from unit grid coordinates to screen coordinates.
-// 4. Triangulates and renders the filled patterns using OpenGL
+// 1. Builds decorated cluster-local geometry for them (see package mesh), in
+// the background, drawing placeholders until it's done.
+// 2. Uploads and renders the filled patterns using OpenGL, placing each
+// cluster through its per-cluster transform on the GPU. Periodic clusters are
+// repeated to cover the viewport, through instanced draws.
 package render
 
 import (
-	"fmt"
+	"image/color"
 	"log"
-	"math"
-	"math/rand"
 	"time"
 
 	"github.com/irfansharif/zellij/internal/fillers"
 	"github.com/irfansharif/zellij/internal/gen"
 	"github.com/irfansharif/zellij/internal/geom"
 	"github.com/irfansharif/zellij/internal/memory"
+	"github.com/irfansharif/zellij/internal/mesh"
 	"github.com/irfansharif/zellij/internal/palette"
+	"github.com/irfansharif/zellij/internal/worker"
 )
 
-const viewportScaleFactor = 0.7
+// maxCopiesPerAxis bounds how many copies of a periodic cluster's translation
+// unit are drawn across (and down) the viewport, when zoomed far out.
+const maxCopiesPerAxis = 128
+
+// maxUploadsPerFrame bounds the number of clusters whose geometry
+// UploadFinished uploads per call, so finishing a large batch of clusters
+// doesn't stall a frame.
+const maxUploadsPerFrame = 8
+
+// placeholderUnits is the side, in grid units, of the square drawn for
+// clusters that don't have any geometry built yet.
+const placeholderUnits = 8
+
+var placeholderColour = color.RGBA{R: 0xe4, G: 0xe4, B: 0xe4, A: 0xff}
 
 type Renderer struct {
 	w, h             int
@@ -28,18 +44,53 @@ type Renderer struct {
 	memController *memory.MemoryController
 	shaderManager *ShaderManager
 	stats         Stats
+
+	// What each cluster's uploaded geometry was built from, or is being built
+	// from if it's pending. Lets us move, rotate or scale clusters by only
+	// updating their transforms, instead of regenerating them.
+	uploaded map[memory.ClusterID]mesh.Cluster
+
+	// Geometry is built in the background; pending holds the latest build
+	// submitted for each cluster, until it's uploaded. Earlier ones are
+	// stale.
+	builds    *worker.Pool[memory.ClusterID, build]
+	pending   map[memory.ClusterID]uint64
+	nextBuild uint64
+}
+
+// build is a cluster's geometry, built in the background.
+type build struct {
+	seq      uint64 // submission it's for, see Renderer.pending
+	vertices []float32
 }
 
 // ClusterRenderData holds rendering information for a single cluster.
 type ClusterRenderData struct {
 	ID            memory.ClusterID // Cluster ID for memory controller
 	Composition   gen.Composition
-	GridBounds    geom.Box
+	UnitSize      float64 // world units per grid unit
 	CanvasPos     geom.Point
 	WorldToScreen geom.Affine
 	Palette       palette.Palette
-	Seed          int64 // seed for deterministic per-cluster effects (e.g., shimmer)
-	Dirty         bool  // whether cluster needs GPU re-upload
+	Seed          int64   // seed for deterministic per-cluster effects (e.g., shimmer)
+	Rotation      float64 // radians, about the cluster's center
+	Scale         float64 // uniform scale about the cluster's center
+	Dirty         bool    // whether cluster needs GPU re-upload
+	Pending       bool    // whether its composition is still being generated (drawn as a placeholder)
+}
+
+// Mesh returns the GL-independent description of the cluster used to build
+// its geometry.
+func (c ClusterRenderData) Mesh() mesh.Cluster {
+	return mesh.Cluster{
+		Composition: c.Composition,
+		UnitSize:    c.UnitSize,
+		CanvasPos:   c.CanvasPos,
+		Palette:     c.Palette,
+		Seed:        c.Seed,
+		Rotation:    c.Rotation,
+		Scale:       c.Scale,
+	}
 }
 
 // Stats tracks rendering performance metrics.
@@ -49,10 +100,16 @@ type Stats struct {
 }
 
 func NewRenderer(memController *memory.MemoryController) *Renderer {
+	shaderManager := NewShaderManager()
+	memController.SetTransformUniforms(shaderManager.uSlotCapacity, shaderManager.uSlotTransforms)
+	memController.SetRepeatUniforms(shaderManager.uPeriods, shaderManager.uRepeat)
 	return &Renderer{
 		zoom:          1.0,
-		shaderManager: NewShaderManager(),
+		shaderManager: shaderManager,
 		memController: memController,
+		uploaded:      make(map[memory.ClusterID]mesh.Cluster),
+		builds:        worker.NewPool[memory.ClusterID, build](0),
+		pending:       make(map[memory.ClusterID]uint64),
 	}
 }
 
@@ -63,7 +120,9 @@ func (r *Renderer) SetView(w, h int, zoom, panX, panY float64) {
 }
 
 // PrepareMulti prepares the renderer for multiple clusters with dirty tracking.
-// Only dirty clusters have their geometry regenerated and uploaded to GPU.
+// Only dirty clusters have their geometry regenerated, in the background (see
+// UploadFinished); until it's done, they're drawn with whatever geometry they
+// had, or a placeholder.
 func (r *Renderer) PrepareMulti(clusters []ClusterRenderData, w, h int) error {
 	startTime := time.Now()
 
@@ -73,65 +132,29 @@ func (r *Renderer) PrepareMulti(clusters []ClusterRenderData, w, h int) error {
 
 	r.w, r.h = w, h
 
-	if len(clusters) == 0 {
-		r.stats = Stats{
-			LastPrepareTimeMs: float64(time.Since(startTime).Microseconds()) / 1000.0,
-		}
-		return nil
-	}
-
-	// Process dirty clusters and cache geometry for potential re-upload after
-	// potential batch growth+move in the memory controller.
-	// TODO(irfansharif): Simplify this structure.
-	dirtyCount := 0
-	clusterGeometry := make(map[memory.ClusterID][]float32) // Cache generated geometry for re-uploads
-
+	r.forgetRemoved(clusters)
 	for i := range clusters {
 		cluster := &clusters[i]
 		if !cluster.Dirty {
 			continue // skip clean clusters
 		}
 
-		// Generate geometry in world/canvas space.
-		vertices := r.generateClusterGeometry(*cluster)
-		if len(vertices) == 0 {
-			log.Printf("WARNING: cluster %d generated no geometry, skipping", cluster.ID)
-			continue
+		// If only the cluster's transform changed, there's nothing to
+		// regenerate. Nor is there anything to generate geometry from for
+		// clusters still waiting on their composition.
+		if !r.transformOnly(*cluster) && !cluster.Pending {
+			r.submitBuild(*cluster)
+			r.uploaded[cluster.ID] = cluster.Mesh()
 		}
 
-		// Cache geometry for potential re-upload after growth.
-		clusterGeometry[cluster.ID] = vertices
-
-		// Upload to memory controller.
-		if err := r.memController.EnsureSlot(cluster.ID, vertices); err != nil {
-			log.Printf("Error uploading cluster %d: %v", cluster.ID, err)
-			continue
-		}
-
-		dirtyCount++
-	}
-
-	// Check if any clusters were affected by batch growth during upload
-	// These clusters need to be re-uploaded because their data was in the old VBO
-	affectedIDs := r.memController.GetAndClearClustersNeedingReupload()
-	for _, clusterID := range affectedIDs {
-		// Check if we have cached geometry (cluster was dirty this frame)
-		vertices, exists := clusterGeometry[clusterID]
-		if !exists {
-			// Cluster was clean, need to regenerate its geometry
-			for i := range clusters {
-				if clusters[i].ID == clusterID {
-					vertices = r.generateClusterGeometry(clusters[i])
-					break
-				}
+		if !r.memController.HasCluster(cluster.ID) {
+			if err := r.memController.EnsureSlot(cluster.ID, placeholderVertices(*cluster)); err != nil {
+				log.Printf("Error uploading placeholder for cluster %d: %v", cluster.ID, err)
+				continue
 			}
 		}
-
-		if len(vertices) > 0 {
-			memClusterID := memory.ClusterID(clusterID)
-			if err := r.memController.EnsureSlot(memClusterID, vertices); err != nil {
-				log.Printf("Error re-uploading cluster %d: %v", clusterID, err)
-			}
+		if err := r.memController.SetTransform(cluster.ID, mesh.Transform(cluster.Mesh())); err != nil {
+			log.Printf("Error updating transform for cluster %d: %v", cluster.ID, err)
 		}
 	}
 
@@ -139,178 +162,126 @@ func (r *Renderer) PrepareMulti(clusters []ClusterRenderData, w, h int) error {
 	return nil
 }
 
-// generateClusterGeometry generates array-based vertex data for a cluster in world/canvas space.
-// This is the core of world-space rendering: geometry is generated once and transformed by
-// view matrix in the shader, so pan/zoom doesn't require regeneration.
-func (r *Renderer) generateClusterGeometry(clusterData ClusterRenderData) []float32 {
-	// Compute bounds for this cluster's composition
-	bounds, err := r.computeModelBounds(clusterData.Composition)
-	if err != nil {
-		return nil
-	}
-
-	// Apply shimmer to the cluster's palette deterministically using the
-	// cluster seed.
-	localRand := rand.New(rand.NewSource(clusterData.Seed))
-	shimmerPal := palette.Shimmered(clusterData.Palette, clusterData.Composition.Shimmer, localRand)
-
-	// Calculate scale from model space to world space
-	// The cluster should maintain a consistent size across the canvas
-	minSide := math.Min(float64(r.w), float64(r.h))
-	referenceGridSide := clusterData.GridBounds.W
-	if referenceGridSide == 0 {
-		referenceGridSide = 1
-	}
-	pixelsPerWorldUnit := (viewportScaleFactor * minSide) / referenceGridSide
-
-	// Compute world-space dimensions
-	worldW := bounds.W * pixelsPerWorldUnit
-	worldH := bounds.H * pixelsPerWorldUnit
-
-	// Create transform from model space to world space
-	// Model bounds → scaled bounds centered at cluster's canvas position
-	worldBounds := geom.MakeBox(
-		clusterData.CanvasPos.X-0.5*worldW,
-		clusterData.CanvasPos.Y-0.5*worldH,
-		worldW,
-		worldH,
-	)
-	modelToWorld := geom.FillBox(bounds, worldBounds, false)
-
-	// Generate triangles for all tiles in world space
-	vertices := make([]float32, 0, len(clusterData.Composition.Tiles)*100) // estimate
+// submitBuild queues up building the cluster's geometry in the background,
+// superseding any build pending for it.
+func (r *Renderer) submitBuild(clusterData ClusterRenderData) {
+	r.nextBuild++
+	seq := r.nextBuild
+	r.pending[clusterData.ID] = seq
+	r.builds.Submit(clusterData.ID, func() build {
+		return build{seq: seq, vertices: generateClusterGeometry(clusterData)}
+	})
+}
 
-	for _, tile := range clusterData.Composition.Tiles {
-		// Transform tile to world coordinates.
-		worldPath := make([]geom.Point, len(tile.Path))
-		for i, p := range tile.Path {
-			worldPath[i] = modelToWorld.MulPoint(p)
+// UploadFinished uploads geometry built in the background since the last call,
+// for up to maxUploadsPerFrame clusters; the rest is left for later calls.
+// It's meant to be called once per frame, before drawing.
+func (r *Renderer) UploadFinished() {
+	for uploads := 0; uploads < maxUploadsPerFrame; {
+		result, ok := r.builds.Next()
+		if !ok {
+			return
 		}
-
-		// Try to match filler pattern.
-		if !r.prepareTileToVertices(worldPath, shimmerPal, &vertices) {
-			log.Printf("WARNING: no filler pattern found for tile %d, skipping", clusterData.ID)
+		id, b := result.Key, result.Value
+		if seq, ok := r.pending[id]; !ok || seq != b.seq {
+			continue // superseded, or the cluster's gone
+		}
+		delete(r.pending, id)
+
+		if len(b.vertices) == 0 {
+			log.Printf("WARNING: cluster %d generated no geometry, skipping", id)
+			delete(r.uploaded, id)
+			if r.memController.HasCluster(id) {
+				if err := r.memController.RemoveCluster(id); err != nil {
+					log.Printf("Error removing cluster %d: %v", id, err)
+				}
+			}
+			continue
 		}
+		if err := r.memController.EnsureSlot(id, b.vertices); err != nil {
+			log.Printf("Error uploading cluster %d: %v", id, err)
+			continue
+		}
+		uploads++
 	}
-
-	return vertices
 }
 
-// prepareTileToVertices generates vertices for a tile with filler pattern, appending to vertices slice.
-// Returns true if filler was applied, false if fallback should be used.
-func (r *Renderer) prepareTileToVertices(tilePath []geom.Point, pal palette.Palette, vertices *[]float32) bool {
-	if len(fillers.Library) == 0 || len(tilePath) == 0 {
-		return false
-	}
-
-	// Generate geometric signature with rotation logic.
-	currentSig, alignedPath, found := fillers.Signature(tilePath)
-	if !found {
+// transformOnly returns whether the cluster's uploaded geometry is still
+// current, with at most its transform (position, rotation, scale) changed.
+func (r *Renderer) transformOnly(clusterData ClusterRenderData) bool {
+	prev, ok := r.uploaded[clusterData.ID]
+	if !ok || !r.memController.HasCluster(clusterData.ID) {
 		return false
 	}
+	return sameGeometry(prev, clusterData.Mesh())
+}
 
-	// Select a filler cluster.
-	matchingClusters := fillers.Library[currentSig]
-	selectedCluster := matchingClusters[len(currentSig)%len(matchingClusters)]
+// sameGeometry returns whether two mesh descriptions produce the same
+// cluster-local geometry, i.e. differ in their transform alone. Compositions
+// are compared by identity (they're never mutated in place), regenerated ones
+// are always considered different.
+func sameGeometry(a, b mesh.Cluster) bool {
+	sameComposition := len(a.Composition.Tiles) == len(b.Composition.Tiles) &&
+		(len(a.Composition.Tiles) == 0 || &a.Composition.Tiles[0] == &b.Composition.Tiles[0]) &&
+		a.Composition.GridSide == b.Composition.GridSide &&
+		a.Composition.Shimmer == b.Composition.Shimmer
+	return sameComposition &&
+		a.UnitSize == b.UnitSize &&
+		a.Palette == b.Palette &&
+		a.Seed == b.Seed
+}
 
-	// Validate cluster.
-	if len(selectedCluster.Bounds) < 2 {
-		return false
+// forgetRemoved drops cached state for clusters no longer around, including
+// builds pending for them.
+func (r *Renderer) forgetRemoved(clusters []ClusterRenderData) {
+	if len(r.uploaded) <= len(clusters) {
+		return // nothing removed
 	}
-
-	// Align cluster to tile using reference segments.
-	clusterRefStart := selectedCluster.Bounds[0]
-	clusterRefEnd := selectedCluster.Bounds[1]
-	tileRefStart := alignedPath[0]
-	tileRefEnd := alignedPath[1]
-	alignmentTransform := geom.MatchTwoSegs(clusterRefStart, clusterRefEnd, tileRefStart, tileRefEnd)
-
-	// Process each decorative shape.
-	for _, shape := range selectedCluster.Shapes {
-		if len(shape.Path) < 3 {
-			continue
-		}
-
-		// Get shape color.
-		clampedIndex := minInt(4, maxInt(0, shape.Colour))
-		shapeColor := pal[clampedIndex]
-
-		// Transform shape vertices to tile space.
-		transformedVertices := make([]geom.Point, len(shape.Path))
-		for j, vertex := range shape.Path {
-			transformedVertices[j] = alignmentTransform.MulPoint(vertex)
-		}
-
-		// Triangulate and append to vertices (array-based: no deduplication).
-		triangles := earClip(transformedVertices)
-		if triangles == nil {
-			continue
-		}
-
-		for _, tri := range triangles {
-			for v := 0; v < 3; v++ {
-				*vertices = append(*vertices,
-					float32(tri[v].X), float32(tri[v].Y), // position
-					float32(shapeColor.R)/255.0, float32(shapeColor.G)/255.0,
-					float32(shapeColor.B)/255.0, float32(shapeColor.A)/255.0, // color
-				)
-			}
+	live := make(map[memory.ClusterID]struct{}, len(clusters))
+	for i := range clusters {
+		live[clusters[i].ID] = struct{}{}
+	}
+	for id := range r.uploaded {
+		if _, ok := live[id]; !ok {
+			delete(r.uploaded, id)
+			delete(r.pending, id)
 		}
 	}
-
-	return true
 }
 
-// computeModelBounds calculates the bounding box for the composition's geometry.
-//
-// Returns the axis-aligned bounding box containing all tiles or boundary points.
-// If boundary is available, uses it for more accurate bounds. Falls back to
-// tile-based bounds if boundary is unavailable.
-//
-// Returns error if no valid geometry is found.
-func (r *Renderer) computeModelBounds(comp gen.Composition) (geom.Box, error) {
-	xmin, xmax := math.MaxFloat64, -math.MaxFloat64
-	ymin, ymax := math.MaxFloat64, -math.MaxFloat64
-	pointCount := 0
-
-	// Try boundary first for more accurate bounds.
-	if len(comp.Boundary) > 0 {
-		for _, p := range comp.Boundary {
-			xmin = math.Min(xmin, p.X)
-			xmax = math.Max(xmax, p.X)
-			ymin = math.Min(ymin, p.Y)
-			ymax = math.Max(ymax, p.Y)
-			pointCount++
-		}
-
-	} else {
-		// Fallback to tile geometry
-		for _, tile := range comp.Tiles {
-			for _, p := range tile.Path {
-				xmin = math.Min(xmin, p.X)
-				xmax = math.Max(xmax, p.X)
-				ymin = math.Min(ymin, p.Y)
-				ymax = math.Max(ymax, p.Y)
-				pointCount++
-			}
+// generateClusterGeometry generates array-based vertex data for a cluster in cluster-local space.
+// This is the core of world-space rendering: geometry is generated once and transformed by
+// the cluster's and view matrix in the shader, so moves and pan/zoom don't require regeneration.
+// It runs on background workers.
+func generateClusterGeometry(clusterData ClusterRenderData) []float32 {
+	polys, unfilled, err := mesh.LocalPolygons(clusterData.Mesh())
+	if err != nil {
+		return nil
+	}
+	if unfilled > 0 {
+		action := "skipping"
+		if fillers.CurrentFallback() != nil {
+			action = "using the fallback"
 		}
-
+		log.Printf("WARNING: no filler pattern found for %d tile(s) in cluster %d, %s", unfilled, clusterData.ID, action)
 	}
+	return mesh.Vertices(polys)
+}
 
-	// Validate computed bounds.
-	if pointCount == 0 {
-		return geom.Box{}, fmt.Errorf("no valid geometry points found in composition")
+// placeholderVertices returns the geometry drawn for a cluster until it has
+// its own: a light grey square, in cluster-local space.
+func placeholderVertices(clusterData ClusterRenderData) []float32 {
+	unitSize := clusterData.UnitSize
+	if unitSize == 0 {
+		unitSize = mesh.DefaultUnitSize
 	}
-	if math.IsInf(xmin, 0) || math.IsInf(xmax, 0) || math.IsInf(ymin, 0) || math.IsInf(ymax, 0) {
-		return geom.Box{}, fmt.Errorf("computed bounds contain infinite values: x[%f,%f] y[%f,%f]", xmin, xmax, ymin, ymax)
-	}
-	if xmin >= xmax || ymin >= ymax {
-		return geom.Box{}, fmt.Errorf("computed bounds are degenerate: x[%f,%f] y[%f,%f]", xmin, xmax, ymin, ymax)
-	}
-
-	width := xmax - xmin
-	height := ymax - ymin
-	return geom.MakeBox(xmin, ymin, width, height), nil
+	half := 0.5 * placeholderUnits * unitSize
+	return mesh.Vertices([]mesh.Polygon{{
+		Colour: placeholderColour,
+		Path: []geom.Point{
+			{X: -half, Y: -half}, {X: half, Y: -half}, {X: half, Y: half}, {X: -half, Y: half},
+		},
+	}})
 }
 
 func (r *Renderer) Draw() {
@@ -319,6 +290,7 @@ func (r *Renderer) Draw() {
 	// Set shader uniforms.
 	matrix := r.computeTransformMatrix()
 	r.shaderManager.SetTransform(matrix)
+	r.updateRepeats()
 
 	// Memory controller handles all draws.
 	if err := r.memController.Draw(); err != nil {
@@ -329,6 +301,73 @@ func (r *Renderer) Draw() {
 	r.stats.LastDrawTimeUs = float64(time.Since(startTime).Microseconds())
 }
 
+// updateRepeats sets the copies of periodic clusters' translation units needed
+// to cover the viewport.
+func (r *Renderer) updateRepeats() {
+	toWorld, err := mesh.ViewTransform(r.w, r.h, r.zoom, r.panX, r.panY).Inv()
+	if err != nil {
+		return
+	}
+	viewport := toWorld.MulBox(geom.MakeBox(0, 0, float64(r.w), float64(r.h)))
+
+	for id, c := range r.uploaded {
+		if !r.memController.HasCluster(id) {
+			continue // removed, forgotten on the next PrepareMulti
+		}
+		var repeat *memory.Repeat // clusters that aren't periodic (anymore) are drawn once
+		if c.Composition.Periodic {
+			if repeat, err = r.repeat(id, c, viewport); err != nil {
+				log.Printf("Error repeating cluster %d: %v", id, err)
+				continue
+			}
+		}
+		if err := r.memController.SetRepeat(id, repeat); err != nil {
+			log.Printf("Error repeating cluster %d: %v", id, err)
+		}
+	}
+}
+
+// repeat returns the copies of a periodic cluster's translation unit needed to
+// cover the given region of world space.
+func (r *Renderer) repeat(id memory.ClusterID, c mesh.Cluster, region geom.Box) (*memory.Repeat, error) {
+	// Our copy of the cluster's transform is stale if it was only moved.
+	transform, _ := r.memController.Transform(id)
+	toLocal, err := transform.Inv()
+	if err != nil {
+		return nil, err
+	}
+	copies, err := mesh.CopiesIn(c, toLocal.MulBox(region))
+	if err != nil {
+		return nil, err
+	}
+	periods, err := mesh.LocalPeriods(c)
+	if err != nil {
+		return nil, err
+	}
+	copies = clampCopies(copies)
+	return &memory.Repeat{
+		Periods: periods,
+		I:       copies.I,
+		J:       copies.J,
+		Cols:    copies.Cols,
+		Rows:    copies.Rows,
+	}, nil
+}
+
+// clampCopies shrinks the range of copies to at most maxCopiesPerAxis along
+// each axis, keeping it centered.
+func clampCopies(cs mesh.Copies) mesh.Copies {
+	if cs.Cols > maxCopiesPerAxis {
+		cs.I += (cs.Cols - maxCopiesPerAxis) / 2
+		cs.Cols = maxCopiesPerAxis
+	}
+	if cs.Rows > maxCopiesPerAxis {
+		cs.J += (cs.Rows - maxCopiesPerAxis) / 2
+		cs.Rows = maxCopiesPerAxis
+	}
+	return cs
+}
+
 // Stats returns the current performance statistics
 func (r *Renderer) Stats() Stats {
 	return r.stats
@@ -337,31 +376,11 @@ func (r *Renderer) Stats() Stats {
 // computeTransformMatrix computes the complete transformation matrix from world
 // coordinates to OpenGL NDC.
 func (r *Renderer) computeTransformMatrix() [16]float32 {
-	transform := geom.MakeAffine(1, 0, 0, 0, 1, 0)
-	transform = r.applyZoomTransform(transform)
-	transform = r.applyPanTransform(transform)
+	transform := mesh.ViewTransform(r.w, r.h, r.zoom, r.panX, r.panY)
 	transform = r.applyScreenToNDCTransform(transform)
 	return r.affineToMatrix4(transform)
 }
 
-// applyZoomTransform applies zoom scaling around the viewport center.
-func (r *Renderer) applyZoomTransform(baseTransform geom.Affine) geom.Affine {
-	viewportCenterX := float64(r.w) / 2.0
-	viewportCenterY := float64(r.h) / 2.0
-
-	translateToOrigin := geom.MakeAffine(1, 0, -viewportCenterX, 0, 1, -viewportCenterY)
-	uniformScale := geom.MakeAffine(r.zoom, 0, 0, 0, r.zoom, 0)
-	translateBack := geom.MakeAffine(1, 0, viewportCenterX, 0, 1, viewportCenterY)
-
-	return translateBack.Mul(uniformScale.Mul(translateToOrigin.Mul(baseTransform)))
-}
-
-// applyPanTransform applies pan translation in screen space.
-func (r *Renderer) applyPanTransform(baseTransform geom.Affine) geom.Affine {
-	panTranslation := geom.MakeAffine(1, 0, r.panX, 0, 1, r.panY)
-	return panTranslation.Mul(baseTransform)
-}
-
 // applyScreenToNDCTransform converts screen coordinates to OpenGL NDC.
 func (r *Renderer) applyScreenToNDCTransform(baseTransform geom.Affine) geom.Affine {
 	screenToNDC := geom.MakeAffine(
@@ -380,19 +399,3 @@ func (r *Renderer) affineToMatrix4(transform geom.Affine) [16]float32 {
 		float32(transform.C), float32(transform.F), 0, 1,
 	}
 }
-
-// minInt returns the minimum of two integers
-func minInt(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-// maxInt returns the maximum of two integers
-func maxInt(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
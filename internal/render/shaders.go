@@ -10,23 +10,43 @@ import (
 // ShaderManager handles OpenGL shader program compilation, linking, and uniform
 // management.
 type ShaderManager struct {
-	program    uint32 // program ID
-	uTransform int32  // uniform location for transformation matrix
+	program         uint32 // program ID
+	uTransform      int32  // uniform location for transformation matrix
+	uSlotCapacity   int32  // uniform location for the batch's per-slot vertex capacity
+	uSlotTransforms int32  // uniform location for the batch's per-slot transforms
+	uPeriods        int32  // uniform location for the period vectors of repeated geometry
+	uRepeat         int32  // uniform location for the first copy and copies per row of repeated geometry
 }
 
-// Vertex shader. Simply applies the uniform transformation matrix to the
-// vertices and forwards the color the the fragment shader.
+// Vertex shader. Offsets repeated geometry by its copy's periods (see
+// memory.Repeat), applies the vertex's per-cluster transform (looked up by
+// slot, see package memory) and then the uniform transformation matrix to the
+// vertices, and forwards the color the the fragment shader.
 const vertexShaderSource = `
 #version 330 core
 layout (location = 0) in vec2 aPos;
 layout (location = 1) in vec4 aColor;
 
 uniform mat4 uTransform;
+uniform int uSlotCapacity;
+uniform samplerBuffer uSlotTransforms;
+uniform vec4 uPeriods; // (x, y) of both period vectors
+uniform ivec3 uRepeat; // first copy (i, j), and copies per row
 
 out vec4 vColor;
 
 void main() {
-    gl_Position = uTransform * vec4(aPos, 0.0, 1.0);
+    int i = uRepeat.x + gl_InstanceID % uRepeat.z;
+    int j = uRepeat.y + gl_InstanceID / uRepeat.z;
+    vec2 offset = float(i)*uPeriods.xy + float(j)*uPeriods.zw;
+
+    int slot = gl_VertexID / uSlotCapacity;
+    vec3 row0 = texelFetch(uSlotTransforms, 2*slot).xyz;
+    vec3 row1 = texelFetch(uSlotTransforms, 2*slot+1).xyz;
+    vec3 local = vec3(aPos + offset, 1.0);
+    vec2 world = vec2(dot(row0, local), dot(row1, local));
+
+    gl_Position = uTransform * vec4(world, 0.0, 1.0);
     vColor = aColor;
 }
 ` + "\x00"
@@ -71,8 +91,12 @@ func NewShaderManager() *ShaderManager {
 		log.Fatalf("Shader linking failed: %s", logText)
 	}
 
-	// Get uniform location.
+	// Get uniform locations.
 	sm.uTransform = gl.GetUniformLocation(sm.program, gl.Str("uTransform\x00"))
+	sm.uSlotCapacity = gl.GetUniformLocation(sm.program, gl.Str("uSlotCapacity\x00"))
+	sm.uSlotTransforms = gl.GetUniformLocation(sm.program, gl.Str("uSlotTransforms\x00"))
+	sm.uPeriods = gl.GetUniformLocation(sm.program, gl.Str("uPeriods\x00"))
+	sm.uRepeat = gl.GetUniformLocation(sm.program, gl.Str("uRepeat\x00"))
 	gl.UseProgram(sm.program) // bind the shader program
 	return sm
 }
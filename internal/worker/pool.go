@@ -0,0 +1,92 @@
+// Package worker runs jobs (generating compositions, building their geometry)
+// on background goroutines, so the main thread, which owns the OpenGL
+// context, never blocks on them. The main thread submits jobs and picks up
+// their results once per frame.
+package worker
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Result is the result of a finished job, along with the key it was submitted
+// under.
+type Result[K comparable, R any] struct {
+	Key   K
+	Value R
+}
+
+// Pool runs jobs, each submitted under a key (e.g. a cluster ID), on a fixed
+// number of background goroutines. At most one job is queued per key:
+// submitting another replaces it, so if jobs for a key come in faster than
+// they're picked up (e.g. when regenerating continuously), only the latest one
+// runs. Jobs already running aren't interrupted; it's up to callers to tell
+// stale results apart.
+type Pool[K comparable, R any] struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	queue []K            // keys with a queued job, in submission order
+	jobs  map[K]func() R // queued jobs, by key
+	done  []Result[K, R] // results not yet picked up, in completion order
+}
+
+// NewPool creates a pool with the given number of workers, or one fewer than
+// the number of CPUs (leaving one for the main thread) if workers <= 0.
+func NewPool[K comparable, R any](workers int) *Pool[K, R] {
+	if workers <= 0 {
+		workers = max(1, runtime.NumCPU()-1)
+	}
+	p := &Pool[K, R]{jobs: make(map[K]func() R)}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues a job under the given key, replacing any job queued under it
+// that hasn't started yet.
+func (p *Pool[K, R]) Submit(key K, job func() R) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.jobs[key]; !ok {
+		p.queue = append(p.queue, key)
+	}
+	p.jobs[key] = job
+	p.cond.Signal()
+}
+
+// Next returns the result of the earliest finished job not yet picked up, if
+// any. It doesn't block.
+func (p *Pool[K, R]) Next() (Result[K, R], bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.done) == 0 {
+		return Result[K, R]{}, false
+	}
+	result := p.done[0]
+	p.done[0] = Result[K, R]{} // don't hold on to it
+	p.done = p.done[1:]
+	return result, true
+}
+
+// work runs queued jobs, forever.
+func (p *Pool[K, R]) work() {
+	for {
+		p.mu.Lock()
+		for len(p.queue) == 0 {
+			p.cond.Wait()
+		}
+		key := p.queue[0]
+		p.queue = p.queue[1:]
+		job := p.jobs[key]
+		delete(p.jobs, key)
+		p.mu.Unlock()
+
+		value := job()
+
+		p.mu.Lock()
+		p.done = append(p.done, Result[K, R]{Key: key, Value: value})
+		p.mu.Unlock()
+	}
+}